@@ -51,6 +51,7 @@ type StepChangelogOptions struct {
 	GenerateCRD         bool
 	GenerateReleaseYaml bool
 	UpdateRelease       bool
+	ChangelogFromMerges bool
 	State               StepChangelogState
 }
 
@@ -127,6 +128,9 @@ e.g. define environment variables GIT_USERNAME and GIT_API_TOKEN
 		# specify the version and a header template
 		jx step changelog --header-file docs/dev/changelog-header.md --version 1.2.3
 
+		# only use the pull request titles of the merge commits
+		jx step changelog --changelog-from-merges
+
 `)
 
 	GitHubIssueRegex = regexp.MustCompile(`(\#\d+)`)
@@ -171,6 +175,7 @@ func NewCmdStepChangelog(f cmdutil.Factory, out io.Writer, errOut io.Writer) *co
 	cmd.Flags().BoolVarP(&options.GenerateCRD, "crd", "c", false, "Generate the CRD in the chart")
 	cmd.Flags().BoolVarP(&options.GenerateReleaseYaml, "generate-yaml", "y", true, "Generate the Release YAML in the local helm chart")
 	cmd.Flags().BoolVarP(&options.UpdateRelease, "update-release", "", true, "Should we update the release on the git repository with the changelog")
+	cmd.Flags().BoolVarP(&options.ChangelogFromMerges, "changelog-from-merges", "", false, "Only use the merge commits, taking the pull request titles from their messages, for repositories which squash or merge pull requests")
 
 	cmd.Flags().StringVarP(&options.Header, "header", "", "", "The changelog header in markdown for the changelog. Can use go template expressions on the ReleaseSpec object: https://golang.org/pkg/text/template/")
 	cmd.Flags().StringVarP(&options.HeaderFile, "header-file", "", "", "The file name of the changelog header in markdown for the changelog. Can use go template expressions on the ReleaseSpec object: https://golang.org/pkg/text/template/")
@@ -334,6 +339,12 @@ func (o *StepChangelogOptions) Run() error {
 
 	if commits != nil {
 		for _, commit := range *commits {
+			if o.ChangelogFromMerges {
+				if len(commit.ParentHashes) < 2 {
+					continue
+				}
+				commit.Message = mergeCommitTitle(commit.Message)
+			}
 			o.addCommit(&release.Spec, &commit)
 		}
 	}
@@ -468,6 +479,29 @@ func (o *StepChangelogOptions) addCommit(spec *v1.ReleaseSpec, commit *object.Co
 	}
 }
 
+// mergeCommitTitle returns the pull request title from a merge commit message such as
+// 'Merge pull request #123 from org/branch' followed by the title, keeping the pull request reference so it is linked
+func mergeCommitTitle(message string) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	subject := strings.TrimSpace(lines[0])
+	title := ""
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			title = line
+			break
+		}
+	}
+	if title == "" {
+		return subject
+	}
+	ref := GitHubIssueRegex.FindString(subject)
+	if ref != "" && !strings.Contains(title, ref) {
+		title = fmt.Sprintf("%s (%s)", title, ref)
+	}
+	return title
+}
+
 func (o *StepChangelogOptions) addIssuesAndPullRequests(spec *v1.ReleaseSpec, commit *v1.CommitSummary, rawCommit *object.Commit) error {
 	tracker := o.State.Tracker
 
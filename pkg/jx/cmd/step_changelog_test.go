@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeCommitTitle(t *testing.T) {
+	assert.Equal(t, "Add the cheese feature (#123)",
+		mergeCommitTitle("Merge pull request #123 from jstrachan/cheese\n\nAdd the cheese feature\n"))
+	assert.Equal(t, "fix: the crash (#45)",
+		mergeCommitTitle("Merge pull request #45 from org/fix\n\nfix: the crash (#45)"))
+	assert.Equal(t, "Update the docs",
+		mergeCommitTitle("Merge branch 'docs' into 'master'\n\nUpdate the docs\n\nSee merge request group/project!12"))
+	assert.Equal(t, "Merge branch 'feature'", mergeCommitTitle("Merge branch 'feature'\n"))
+}
@@ -1,15 +1,20 @@
 package cmd
 
 import (
-	"bufio"
-	"encoding/xml"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
 
 	"encoding/json"
 
@@ -19,28 +24,188 @@ import (
 	"github.com/jenkins-x/jx/pkg/jx/cmd/templates"
 	cmdutil "github.com/jenkins-x/jx/pkg/jx/cmd/util"
 	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 const (
-	packagejson = "package.json"
-	chartyaml   = "Chart.yaml"
-	pomxml      = "pom.xml"
-	makefile    = "Makefile"
+	packagejson         = "package.json"
+	chartyaml           = "Chart.yaml"
+	pomxml              = "pom.xml"
+	makefile            = "Makefile"
+	projectclj          = "project.clj"
+	mesonbuild          = "meson.build"
+	configureac         = "configure.ac"
+	swiftpkg            = "Package.swift"
+	snapcraftyaml       = "snapcraft.yaml"
+	bumpversion         = ".bumpversion.cfg"
+	elmjson             = "elm.json"
+	denojson            = "deno.json"
+	denojsonc           = "deno.jsonc"
+	pkgbuild            = "PKGBUILD"
+	changelogmd         = "CHANGELOG.md"
+	dockerfile          = "Dockerfile"
+	vagrantfile         = "Vagrantfile"
+	infoplist           = "Info.plist"
+	cargotoml           = "Cargo.toml"
+	kustomizationyaml   = "kustomization.yaml"
+	kustomizationyml    = "kustomization.yml"
+	vcpkgjson           = "vcpkg.json"
+	conanfilepy         = "conanfile.py"
+	dotversion          = ".version"
+	cmakelists          = "CMakeLists.txt"
+	modulebazel         = "MODULE.bazel"
+	openapiyaml         = "openapi.yaml"
+	openapiyml          = "openapi.yml"
+	openapijson         = "openapi.json"
+	swaggeryaml         = "swagger.yaml"
+	swaggeryml          = "swagger.yml"
+	swaggerjson         = "swagger.json"
+	dockercomposeyml    = "docker-compose.yml"
+	dockercomposeyaml   = "docker-compose.yaml"
+	composeyml          = "compose.yml"
+	composeyaml         = "compose.yaml"
+	ansiblemetayml      = "meta/main.yml"
+	ansiblemetayaml     = "meta/main.yaml"
+	projecttoml         = "Project.toml"
+	pubspecyaml         = "pubspec.yaml"
+	releasepleasejson   = ".release-please-manifest.json"
+	amperyaml           = "module.yaml"
+	gemfilelock         = "Gemfile.lock"
+	directorybuildprops = "Directory.Build.props"
+	melosyaml           = "melos.yaml"
+	dotcztoml           = ".cz.toml"
+	cztoml              = "cz.toml"
+	libsversionstoml    = "libs.versions.toml"
+	settingsgradle      = "settings.gradle"
+	settingsgradlekts   = "settings.gradle.kts"
+
+	// debianChangelog is matched by base name as debian/changelog lives in the debian packaging directory
+	debianChangelog = "changelog"
+
+	gemspecExt    = ".gemspec"
+	podspecExt    = ".podspec"
+	rbExt         = ".rb"
+	wxsExt        = ".wxs"
+	tfExt         = ".tf"
+	propertiesExt = ".properties"
+	specExt       = ".spec"
+	nixExt        = ".nix"
+	pmExt         = ".pm"
+	cabalExt      = ".cabal"
+	phpExt        = ".php"
+	dprojExt      = ".dproj"
+
+	baseTag  = "tag"
+	baseFile = "file"
+
+	tagSortSemver      = "semver"
+	tagSortCreatorDate = "creatordate"
+
+	// commitizenTable is the table of the commitizen configuration of a pyproject.toml or .cz.toml
+	commitizenTable = "tool.commitizen"
+
+	chartVersion    = "version"
+	chartAppVersion = "appVersion"
+
+	plistShortVersion = "CFBundleShortVersionString"
 )
 
 // StepNextVersionOptions contains the command line flags
 type StepNextVersionOptions struct {
-	Filename      string
-	Dir           string
-	Tag           bool
-	UseGitTagOnly bool
-	NewVersion    string
+	Filename                   string
+	Dir                        string
+	Tag                        bool
+	UseGitTagOnly              bool
+	IncrementBuildOnly         bool
+	NoVersionFile              bool
+	SignCommit                 bool
+	CommitAuthorName           string
+	CommitAuthorEmail          string
+	FetchRetries               int
+	FetchRetryDelay            string
+	Workspace                  string
+	ValidateIncrement          bool
+	RequireLiteral             bool
+	UpdateChangelog            bool
+	ChangelogDateFormat        string
+	Base                       string
+	PreTagHook                 string
+	PostTagHook                string
+	UndoTagOnPostHookFailure   bool
+	HclAttribute               string
+	MaxVersion                 string
+	MinVersion                 string
+	ChartField                 string
+	PrintPrevious              bool
+	PropertyKey                string
+	SkipExistingTag            bool
+	ResetRelease               bool
+	VersionKeys                []string
+	TagFormat                  string
+	EnvFile                    string
+	ForcePatchOnEqual          bool
+	PlistKey                   string
+	PrintOnly                  bool
+	KustomizeImage             string
+	ShowDiff                   bool
+	AllowDirty                 bool
+	Config                     string
+	TagPrefix                  string
+	Channel                    string
+	NoVTag                     bool
+	FallbackToTag              bool
+	EmitComponents             bool
+	ComposeService             string
+	NoPush                     bool
+	SinceTag                   string
+	Output                     string
+	AllowRetag                 bool
+	Preview                    bool
+	PullRequest                string
+	Strategy                   string
+	Regex                      string
+	Group                      int
+	WriteRegex                 string
+	WriteTemplate              string
+	RespectZerover             bool
+	YamlPath                   string
+	TagsFile                   string
+	IncrementBuildNumber       bool
+	Quiet                      bool
+	ManifestPath               string
+	TagAnnotationFromChangelog bool
+	LockGem                    string
+	IncrementPrereleaseOnly    bool
+	JSONPath                   string
+	NoTagOnNoChange            bool
+	PrereleaseSeparator        string
+	MetadataSeparator          string
+	BuildMetadata              string
+	AutoUnshallow              bool
+	ChartSyncAppVersion        bool
+	TagSort                    string
+	Package                    string
+	DryRun                     bool
+	Commitizen                 bool
+	CatalogKey                 string
+	PrereleaseFromBranch       bool
+	WriteOnly                  bool
+	NewVersion                 string
 	StepOptions
 }
 
 type Project struct {
-	Version string `xml:"version"`
+	Version    string            `xml:"version"`
+	Properties ProjectProperties `xml:"properties"`
+}
+
+// ProjectProperties are the properties of a pom.xml used by CI friendly versions such as ${revision}
+type ProjectProperties struct {
+	Revision   string `xml:"revision"`
+	Sha1       string `xml:"sha1"`
+	Changelist string `xml:"changelist"`
 }
 
 type PackageJSON struct {
@@ -50,6 +215,18 @@ type PackageJSON struct {
 var (
 	StepNextVersionLong = templates.LongDesc(`
 		This pipeline step command works out a semantic version, writes a file ./VERSION and optionally updates a file
+
+		The latest version is found from the git tags, where a leading 'v' is ignored so both v1.2.3 and 1.2.3 tags are
+		versions. New tags are created with the 'v' prefix (e.g. v1.2.3) unless '--no-v-tag' is used to create bare
+		tags such as 1.2.3. The ./VERSION file never includes the prefix.
+
+		Without any tags the first version is worked out from 0.0.0, so the first release is 0.0.1. There is no flag for
+		the first version, instead use '--min-version' such as '--min-version 2.0.0' which is used whenever the version
+		worked out would be less than it, whether that's the first release or a project migrated from older tags.
+		A version given with '--version' is used as is.
+
+		Swift packages are versioned purely by git tags so using '--filename Package.swift' works out the version from
+		the latest tag only. Tags are created with a 'v' prefix (e.g. v1.2.3) which SwiftPM accepts.
 `)
 
 	StepNextVersionExample = templates.Examples(`
@@ -57,11 +234,40 @@ var (
 		jx step next-version --filename package.json
 		jx step next-version --filename package.json --tag
 		jx step next-version --filename package.json --tag --version 1.2.3
+		jx step next-version --filename package.json --version 1.2.3 --write-only
+		jx step next-version --filename package.json --workspace my-lib
+		jx step next-version --filename melos.yaml --package my_package --tag
+		jx step next-version --filename pyproject.toml --commitizen --tag
+		jx step next-version --filename gradle/libs.versions.toml --catalog-key myapp --tag
+		jx step next-version --filename package.json --tag --update-changelog
+		jx step next-version --filename package.json --tag --update-changelog --tag-annotation-from-changelog
+		jx step next-version --filename package.json --tag --tag-format "release-{{.Version}}" --dry-run
+		jx step next-version --filename pom.xml --base file
+		jx step next-version --filename package.json --tag --pre-tag-hook "./check-build.sh"
+		jx step next-version --filename package.json --tag --post-tag-hook "./deploy.sh \$TAG"
+		jx step next-version --filename versions.tf --hcl-attribute module_version
+		jx step next-version --filename src/main/resources/version.properties --property-key app.version
+		jx step next-version --use-git-tag-only --increment-build-only
+		jx step next-version --use-git-tag-only --tag --no-version-file
+		jx step next-version --config .jx-versions.yaml --tag
+		jx step next-version --use-git-tag-only --preview --pr 23
+		jx step next-version --filename package.json --channel nightly --increment-prerelease-only --tag
+		jx step next-version --filename package.json --prerelease-from-branch --tag
+		jx step next-version --filename .tool-versions --regex "(?m)^my-tool (\S+)$" --tag
+		jx step next-version --filename README.md --use-git-tag-only --write-regex "my-tool@(\S+)" --write-template "v{{.Version}}"
 `)
 )
 
 func NewCmdStepNextVersion(f cmdutil.Factory, out io.Writer, errOut io.Writer) *cobra.Command {
-	options := StepNextVersionOptions{}
+	options := StepNextVersionOptions{
+		StepOptions: StepOptions{
+			CommonOptions: CommonOptions{
+				Factory: f,
+				Out:     out,
+				Err:     errOut,
+			},
+		},
+	}
 	cmd := &cobra.Command{
 		Use:     "next-version",
 		Short:   "Writes next semantic version",
@@ -75,321 +281,1921 @@ func NewCmdStepNextVersion(f cmdutil.Factory, out io.Writer, errOut io.Writer) *
 		},
 	}
 	cmd.Flags().StringVarP(&options.Filename, "filename", "f", "", "Filename that contains version property to update, e.g. package.json")
-	cmd.Flags().StringVarP(&options.NewVersion, "version", "", "", "optional version to use rather than generating a new one")
+	cmd.Flags().StringVarP(&options.NewVersion, "version", "", "", "optional version to use rather than generating a new one, any 'v' prefix is removed as the tag prefix is added to the tag")
 	cmd.Flags().StringVarP(&options.Dir, "dir", "d", "", "the directory to look for files that contain a pom.xml or Makefile with the project version to bump")
 	cmd.Flags().BoolVarP(&options.Tag, "tag", "t", false, "tag and push new version")
-	cmd.Flags().BoolVarP(&options.UseGitTagOnly, "use-git-tag-only", "", false, "only use a git tag so work out new semantic version, else specify filename [pom.xml,package.json,Makefile,Chart.yaml]")
+	cmd.Flags().BoolVarP(&options.UseGitTagOnly, "use-git-tag-only", "", false, "only use a git tag so work out new semantic version, else specify filename ["+strings.Join(supportedVersionFiles(), ",")+"]")
+	cmd.Flags().BoolVarP(&options.NoVersionFile, "no-version-file", "", false, "do not write the new version to a ./VERSION file")
+	cmd.Flags().BoolVarP(&options.SignCommit, "sign-commit", "", false, "GPG sign the release commit made when updating the version file")
+	cmd.Flags().StringVarP(&options.CommitAuthorName, "commit-author-name", "", "", "the name of the author and committer of the release commit, defaults to the git user.name")
+	cmd.Flags().StringVarP(&options.CommitAuthorEmail, "commit-author-email", "", "", "the email of the author and committer of the release commit, defaults to the git user.email")
+	cmd.Flags().StringVarP(&options.Workspace, "workspace", "", "", "the name of the npm/yarn workspace whose packages/<name>/package.json contains the version, when using package.json")
+	cmd.Flags().BoolVarP(&options.ValidateIncrement, "validate-increment", "", false, "fail if the new version is not greater than the latest existing git tag")
+	cmd.Flags().BoolVarP(&options.RequireLiteral, "require-literal", "", false, "fail if a .gemspec or .podspec version is not a string literal rather than falling back to git tags only")
+	cmd.Flags().BoolVarP(&options.UpdateChangelog, "update-changelog", "", false, "add a heading for the new version to the CHANGELOG.md and include it in the release commit")
+	cmd.Flags().StringVarP(&options.ChangelogDateFormat, "changelog-date-format", "", "2006-01-02", "the Go time layout of the date in the CHANGELOG.md version heading")
+	cmd.Flags().StringVarP(&options.Base, "base", "", baseTag, "the version to increment, either the latest git 'tag' (moving ahead if the file version is greater) or the 'file' version ignoring tags")
+	cmd.Flags().StringVarP(&options.PreTagHook, "pre-tag-hook", "", "", "a shell command to run before tagging with the new version in the $VERSION environment variable, a failure aborts the tag")
+	cmd.Flags().StringVarP(&options.PostTagHook, "post-tag-hook", "", "", "a shell command to run after tagging with the new version and tag in the $VERSION and $TAG environment variables, a failure is reported but the tag is kept")
+	cmd.Flags().BoolVarP(&options.UndoTagOnPostHookFailure, "undo-tag-on-post-hook-failure", "", false, "delete the tag locally and from the remote if the post tag hook fails")
+	cmd.Flags().StringVarP(&options.HclAttribute, "hcl-attribute", "", "version", "the attribute containing the version when using a Terraform .tf file")
+	cmd.Flags().StringVarP(&options.MaxVersion, "max-version", "", "", "fail if the new version is greater than this version")
+	cmd.Flags().StringVarP(&options.MinVersion, "min-version", "", "", "use this version if the new version worked out would be less than it, e.g. for the first release when there are no tags")
+	cmd.Flags().StringVarP(&options.ChartField, "chart-field", "", chartVersion, "the field of the Chart.yaml containing the version, either 'version' or 'appVersion'")
+	cmd.Flags().BoolVarP(&options.PrintPrevious, "print-previous", "", false, "print the previous version along with the new version and write it to a ./PREVIOUS_VERSION file")
+	cmd.Flags().StringVarP(&options.PropertyKey, "property-key", "", "version", "the key of the version when using a .properties file, e.g. app.version")
+	cmd.Flags().BoolVarP(&options.SkipExistingTag, "skip-existing-tag", "", false, "do not fail if the tag for the new version already exists, just skip tagging")
+	cmd.Flags().BoolVarP(&options.ResetRelease, "reset-release", "", false, "reset the Release of an RPM .spec file to 1 when updating its Version")
+	cmd.Flags().StringArrayVarP(&options.VersionKeys, "version-key", "", []string{"VERSION"}, "the variables assigned the version in a Makefile or Dockerfile, every assignment of each key is updated")
+	cmd.Flags().StringVarP(&options.TagFormat, "tag-format", "", "", "a Go template for the tag name using {{.Version}}, {{.Major}}, {{.Minor}}, {{.Patch}}, {{.Year}}, {{.Month}} and {{.Day}}, e.g. release/{{.Year}}/v{{.Version}}, defaults to v{{.Version}}")
+	cmd.Flags().StringVarP(&options.EnvFile, "env-file", "", "", "write VERSION, PREVIOUS_VERSION and TAG lines to this file so later stages can source it")
+	cmd.Flags().BoolVarP(&options.ForcePatchOnEqual, "force-patch-on-equal", "", false, "increment the patch even when the file version equals the latest tag and HEAD is already tagged with it, rather than reusing that version")
+	cmd.Flags().StringVarP(&options.PlistKey, "plist-key", "", plistShortVersion, "the key of the version when using an Info.plist, e.g. CFBundleVersion")
+	cmd.Flags().BoolVarP(&options.PrintOnly, "print-only", "", false, "only print the new version to stdout without any other output, writing any files, committing or tagging")
+	cmd.Flags().StringVarP(&options.KustomizeImage, "kustomize-image", "", "", "the name of the image in the images of a kustomization.yaml whose newTag is the version")
+	cmd.Flags().BoolVarP(&options.ShowDiff, "show-diff", "", false, "print a unified diff of the changes made to the version file")
+	cmd.Flags().BoolVarP(&options.AllowDirty, "allow-dirty", "", false, "allow committing and tagging the release when the git working tree has uncommitted changes")
+	cmd.Flags().StringVarP(&options.Config, "config", "", "", "a versions config file such as .jx-versions.yaml listing the path, tagPrefix and filename of each component of a monorepo to version independently")
+	cmd.Flags().StringVarP(&options.Channel, "channel", "", "", "the release channel such as next or beta, giving prerelease versions like 1.2.4-beta.1 numbered independently of the stable 'latest' channel")
+	cmd.Flags().BoolVarP(&options.NoVTag, "no-v-tag", "", false, "create the tag as the bare version such as 1.2.3 rather than prefixing it with v")
+	cmd.Flags().BoolVarP(&options.FallbackToTag, "fallback-to-tag", "", false, "use only the latest git tag if the version file is missing or has no version rather than failing")
+	cmd.Flags().BoolVarP(&options.EmitComponents, "emit-components", "", false, "also write the MAJOR, MINOR, PATCH and PRERELEASE parts of the new version to separate files and to any env file")
+	cmd.Flags().StringVarP(&options.ComposeService, "compose-service", "", "", "the name of the service in a docker-compose.yml whose image tag is the version")
+	cmd.Flags().BoolVarP(&options.NoPush, "no-push", "", false, "only create the tag locally rather than also pushing it to the remote origin")
+	cmd.Flags().StringVarP(&options.SinceTag, "since-tag", "", "", "the tag to increment the version from rather than the latest tag, e.g. for a hotfix branch")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", "", "the output format of any error, use 'json' for an object with the error message and a stable error code")
+	cmd.Flags().BoolVarP(&options.AllowRetag, "allow-retag", "", false, "tag HEAD with the new version even if it is already tagged with another version")
+	cmd.Flags().BoolVarP(&options.Preview, "preview", "", false, "give a preview version such as 1.2.4-pr.23.abc1234 from the next version, the pull request and the short commit sha")
+	cmd.Flags().StringVarP(&options.PullRequest, "pr", "", "", "the pull request of a preview version (e.g. 'PR-23' or just '23'), defaults to $BRANCH_NAME")
+	cmd.Flags().StringVarP(&options.Strategy, "strategy", "", strategyPatch, "how to increment the latest tag: patch, minor, major or conventional to increment from the conventional commit messages since the tag")
+	cmd.Flags().StringVarP(&options.Regex, "regex", "", "", "a regular expression to find the version in any file given by the flag filename")
+	cmd.Flags().IntVarP(&options.Group, "group", "", 1, "the capture group of the regex which is the version")
+	cmd.Flags().StringVarP(&options.WriteRegex, "write-regex", "", "", "a regular expression whose capture group given by the flag group is replaced with the new version, defaults to the regex")
+	cmd.Flags().StringVarP(&options.WriteTemplate, "write-template", "", "", "a template of the text written in place of the write-regex capture group, e.g. 'v{{.Version}}' or '{{.Major}}.{{.Minor}}'")
+	cmd.Flags().BoolVarP(&options.RespectZerover, "respect-zerover", "", false, "with the conventional strategy a breaking change increments the minor rather than the major version while the version is 0.y.z")
+	cmd.Flags().StringVarP(&options.YamlPath, "yaml-path", "", "", "the dotted path of the version in any YAML file given by the flag filename, e.g. default.app.version")
+	cmd.Flags().StringVarP(&options.TagsFile, "tags-file", "", "", "a file listing the existing tags one per line to use rather than the git tags, e.g. for a shallow clone without tags")
+	cmd.Flags().BoolVarP(&options.IncrementBuildNumber, "increment-build-number", "", false, "increment the build number after the + of a pubspec.yaml version, e.g. 1.2.3+45 becomes 1.2.4+46")
+	cmd.Flags().BoolVarP(&options.Quiet, "quiet", "q", false, "only print the new version to stdout once the step has finished, any warnings and errors are written to stderr")
+	cmd.Flags().StringVarP(&options.ManifestPath, "manifest-path", "", ".", "the path of the component whose version to use in a .release-please-manifest.json")
+	cmd.Flags().BoolVarP(&options.TagAnnotationFromChangelog, "tag-annotation-from-changelog", "", false, "use the release's section of the CHANGELOG.md, or else the commits since the previous tag, as the message of the annotated tag")
+	cmd.Flags().StringVarP(&options.LockGem, "lock-gem", "", "", "the name of the gem of the repository in the PATH specs of a Gemfile.lock, needed if there is more than one")
+	cmd.Flags().BoolVarP(&options.IncrementPrereleaseOnly, "increment-prerelease-only", "", false, "only increment the number of the prerelease of the channel, e.g. 1.3.0-nightly.4 becomes 1.3.0-nightly.5, keeping the version of the file or latest channel tag")
+	cmd.Flags().StringVarP(&options.JSONPath, "json-path", "", "", "the dotted path of the version in any JSON file given by the flag filename, e.g. $.properties.image.properties.tag.default")
+	cmd.Flags().BoolVarP(&options.NoTagOnNoChange, "no-tag-on-no-change", "", false, "skip tagging if the new version is the same as the latest tag, e.g. when a pipeline is rerun")
+	cmd.Flags().StringVarP(&options.PrereleaseSeparator, "prerelease-separator", "", ".", "the separator between the channel and the prerelease number, one of '.', '-' or 'none' to give versions like 1.2.4-rc.1 or 1.2.4-rc1")
+	cmd.Flags().StringVarP(&options.MetadataSeparator, "metadata-separator", "", ".", "the separator between the build metadata label and $BUILD_NUMBER, one of '.', '-' or 'none' to give versions like 1.2.4+build.5 or 1.2.4+build5")
+	cmd.Flags().StringVarP(&options.BuildMetadata, "build-metadata", "", "", "a label such as build to join to $BUILD_NUMBER as the build metadata of the new version")
+	cmd.Flags().BoolVarP(&options.AutoUnshallow, "auto-unshallow", "", false, "fetch the full history of a shallow clone along with the tags so that older tags are found")
+	cmd.Flags().BoolVarP(&options.ChartSyncAppVersion, "chart-sync-appversion", "", false, "update both the version and appVersion of a Chart.yaml to the new version, adding the appVersion if there isn't one")
+	cmd.Flags().StringVarP(&options.TagSort, "tag-sort", "", tagSortSemver, "how the latest tag is chosen, either 'semver' for the highest version or 'creatordate' for the most recently created tag")
+	cmd.Flags().StringVarP(&options.Package, "package", "", "", "the name of the package of the Melos workspace whose pubspec.yaml contains the version, when using melos.yaml")
+	cmd.Flags().BoolVarP(&options.DryRun, "dry-run", "", false, "print the new version along with the name and message of the tag which would be created without writing any files, committing or tagging")
+	cmd.Flags().BoolVarP(&options.Commitizen, "commitizen", "", false, "use the version of the [tool.commitizen] table of a TOML file such as pyproject.toml, as bumped by commitizen")
+	cmd.Flags().StringVarP(&options.CatalogKey, "catalog-key", "", "", "the key of the [versions] table of a Gradle version catalog such as gradle/libs.versions.toml containing the version")
+	cmd.Flags().BoolVarP(&options.PrereleaseFromBranch, "prerelease-from-branch", "", false, "use the current git branch as the channel, so release/1.3 gives prerelease versions like 1.3.1-release-1-3.1")
+	cmd.Flags().BoolVarP(&options.WriteOnly, "write-only", "", false, "only write the version given with the flag version to the file of the flag filename and commit it, without working out a new version or tagging")
+	cmd.Flags().IntVarP(&options.FetchRetries, "fetch-retries", "", 3, "number of times to retry fetching git tags after a transient failure")
+	cmd.Flags().StringVarP(&options.FetchRetryDelay, "fetch-retry-delay", "", "1s", "initial delay before retrying a failed git tag fetch, doubled after each attempt")
+	cmd.Flags().BoolVarP(&options.IncrementBuildOnly, "increment-build-only", "", false, "only increment the fourth (build) segment of a 4 part version tag, e.g. 1.2.3.0 becomes 1.2.3.1")
 
 	options.addCommonFlags(cmd)
 	return cmd
 }
 
 func (o *StepNextVersionOptions) Run() error {
+	err := o.run()
+	if err != nil && o.Output == outputJSON {
+		o.printJSONError(err)
+	}
+	return err
+}
+
+func (o *StepNextVersionOptions) run() error {
 
 	var err error
-	if o.NewVersion == "" {
-		o.NewVersion, err = o.getNewVersionFromTag()
+	if o.Output != "" && o.Output != outputJSON {
+		return versionErrorf(errCodeInvalidFlag, "unknown output %s, the only output format is %s", o.Output, outputJSON)
+	}
+	if o.PrintOnly || o.Quiet {
+		log.SetQuiet(true)
+		defer log.SetQuiet(false)
+	}
+	if o.ChartField != "" && o.ChartField != chartVersion && o.ChartField != chartAppVersion {
+		return versionErrorf(errCodeInvalidFlag, "unknown chart-field %s, choose %s or %s", o.ChartField, chartVersion, chartAppVersion)
+	}
+
+	if o.TagSort != "" && o.TagSort != tagSortSemver && o.TagSort != tagSortCreatorDate {
+		return versionErrorf(errCodeInvalidFlag, "unknown tag-sort %s, choose %s or %s", o.TagSort, tagSortSemver, tagSortCreatorDate)
+	}
+	if o.TagSort == tagSortCreatorDate && o.TagsFile != "" {
+		return versionErrorf(errCodeInvalidFlag, "the tags of a tags file have no creation date so cannot be sorted by %s", tagSortCreatorDate)
+	}
+
+	if o.PrereleaseFromBranch {
+		if o.Channel != "" {
+			return versionErrorf(errCodeInvalidFlag, "the flags channel and prerelease-from-branch cannot be used together")
+		}
+		o.Channel, err = o.branchChannel()
 		if err != nil {
 			return err
 		}
 	}
 
-	// in declaritive pipelines we sometimes need to write the version to a file rather than pass state
-	err = ioutil.WriteFile("VERSION", []byte(o.NewVersion), 0755)
-	if err != nil {
-		return err
+	if o.Channel != "" && !channelRegex.MatchString(o.Channel) {
+		return versionErrorf(errCodeInvalidFlag, "invalid channel %s, a channel can only contain alphanumerics and hyphens", o.Channel)
 	}
 
-	// if filename flag set and recognised then update version, commit
-	if o.Filename != "" {
-		err = o.setVersion()
+	if o.IncrementPrereleaseOnly && !o.isPrereleaseChannel() {
+		return versionErrorf(errCodeInvalidFlag, "please specify the prerelease to increment with the flag channel, e.g. --channel nightly")
+	}
+
+	if o.Preview && (o.Tag || o.isPrereleaseChannel()) {
+		return versionErrorf(errCodeInvalidFlag, "a preview version is only for an ephemeral preview environment so cannot be tagged or released to a channel")
+	}
+
+	for name, separator := range map[string]string{"prerelease-separator": o.PrereleaseSeparator, "metadata-separator": o.MetadataSeparator} {
+		if !isValidSeparator(separator) {
+			return versionErrorf(errCodeInvalidFlag, "invalid %s %q, must be one of %s", name, separator, strings.Join(validSeparators, ", "))
+		}
+	}
+
+	if filepath.Base(o.Filename) == libsversionstoml && o.CatalogKey == "" {
+		return versionErrorf(errCodeInvalidFlag, "please specify the key of the version in the [versions] of %s with the flag catalog-key", o.Filename)
+	}
+
+	// the tag prefix is added when tagging so a version like v1.2.3 would otherwise be tagged vv1.2.3
+	o.NewVersion = trimVersionPrefix(o.NewVersion)
+
+	if o.WriteOnly {
+		return o.writeVersion()
+	}
+
+	if o.Config != "" {
+		return o.runComponents()
+	}
+
+	if o.NewVersion == "" || o.Tag {
+		err = o.verifyHasCommits()
 		if err != nil {
 			return err
 		}
 	}
 
-	// if tag set then tag it
-	if o.Tag {
-		tagOptions := StepTagOptions{
-			Flags: StepTagFlags{
-				Version: o.NewVersion,
-			},
-			StepOptions: o.StepOptions,
+	previousVersion := ""
+	if o.NewVersion == "" && o.IncrementPrereleaseOnly {
+		o.NewVersion, previousVersion, err = o.prereleaseOnlyVersion()
+		if err != nil {
+			return err
 		}
-		err = tagOptions.Run()
+	} else if o.NewVersion == "" {
+		o.NewVersion, previousVersion, err = o.getNewVersionFromTag()
 		if err != nil {
 			return err
 		}
+		if o.MinVersion != "" {
+			o.NewVersion, err = applyMinVersion(o.NewVersion, o.MinVersion)
+			if err != nil {
+				return err
+			}
+		}
+		if o.isPrereleaseChannel() {
+			o.NewVersion, err = o.channelVersion(o.NewVersion)
+			if err != nil {
+				return err
+			}
+		}
+		if o.Preview {
+			o.NewVersion, err = o.previewVersion(o.NewVersion)
+			if err != nil {
+				return err
+			}
+		}
+		if o.BuildMetadata != "" {
+			o.NewVersion = o.buildMetadataVersion(o.NewVersion)
+		}
+	} else if o.PrintPrevious || o.EnvFile != "" {
+		previousVersion, err = o.getBaseTag()
+		if err != nil && previousVersion == "" {
+			return err
+		}
 	}
-	return nil
-}
 
-// gets the version from a source file
-func (o *StepNextVersionOptions) getVersion() (string, error) {
-	if o.UseGitTagOnly {
-		return "", nil
+	if o.ValidateIncrement {
+		latest, err := o.getLatestTag()
+		if err != nil && latest == "" {
+			return err
+		}
+		err = validateIncrement(o.NewVersion, latest)
+		if err != nil {
+			return err
+		}
 	}
-	if o.Filename == "" {
-		// try and work out
-		return "", fmt.Errorf("no filename flag set to work out next semantic version.  choose pom.xml, Chart.yaml, package.json, Makefile or set the flag use-git-tag-only")
+
+	if o.MaxVersion != "" {
+		err = validateMaxVersion(o.NewVersion, o.MaxVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.PrintOnly {
+		_, err = fmt.Fprintln(o.Out, o.NewVersion)
+		return err
+	}
+
+	if o.DryRun {
+		return o.printDryRun()
 	}
 
-	switch o.Filename {
-	case chartyaml:
-		chartFile := filepath.Join(o.Dir, chartyaml)
-		chart, err := ioutil.ReadFile(chartFile)
+	tagRelease := o.Tag
+	if o.Tag && o.NoTagOnNoChange {
+		unchanged, err := o.versionUnchanged()
 		if err != nil {
-			return "", err
+			return err
 		}
+		if unchanged {
+			log.Infof("version %s is unchanged from the latest tag so not tagging it again\n", o.NewVersion)
+			tagRelease = false
+		}
+	}
 
-		if o.Verbose {
-			log.Infof("Found Chart.yaml\n")
-		}
-		scanner := bufio.NewScanner(strings.NewReader(string(chart)))
-		for scanner.Scan() {
-			if strings.Contains(scanner.Text(), "version") {
-				parts := strings.Split(scanner.Text(), ":")
-
-				v := strings.TrimSpace(parts[1])
-				if v != "" {
-					if o.Verbose {
-						log.Infof("existing Chart version %v\n", v)
-					}
-					return v, nil
-				}
-			}
+	if !o.AllowDirty && (o.Tag || o.Filename != "" || o.UpdateChangelog) {
+		err = o.verifyClean()
+		if err != nil {
+			return err
 		}
-	case packagejson:
-		packageFile := filepath.Join(o.Dir, packagejson)
-		p, err := ioutil.ReadFile(packageFile)
+	}
+
+	// work out the tag name up front so an invalid tag format fails before anything is changed
+	tag := ""
+	if o.Tag || o.EnvFile != "" {
+		tag, err = o.tagName(time.Now())
 		if err != nil {
-			return "", err
+			return err
 		}
+	}
 
-		if o.Verbose {
-			log.Infof("found %s\n", packagejson)
+	// check the tag doesn't exist before anything is written or committed for the release
+	alreadyTagged := false
+	if tagRelease {
+		alreadyTagged, err = o.tagExists(tag)
+		if err != nil {
+			return err
 		}
-		var jsPackage PackageJSON
-		json.Unmarshal(p, &jsPackage)
+		if alreadyTagged && !o.SkipExistingTag {
+			return versionErrorf(errCodeTagExists, "tag %s already exists, use the flag skip-existing-tag to ignore existing tags", tag)
+		}
+		if alreadyTagged {
+			log.Infof("tag %s already exists so not releasing it again\n", tag)
+			tagRelease = false
+		}
+	}
 
-		if jsPackage.Version != "" {
-			if o.Verbose {
-				log.Infof("existing version %s\n", jsPackage.Version)
+	var components *VersionComponents
+	if o.EmitComponents {
+		components, err = parseVersionComponents(o.NewVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	// in declaritive pipelines we sometimes need to write the version to a file rather than pass state
+	if !o.NoVersionFile {
+		err = ioutil.WriteFile("VERSION", []byte(o.NewVersion), 0755)
+		if err != nil {
+			return err
+		}
+		if o.PrintPrevious {
+			err = ioutil.WriteFile("PREVIOUS_VERSION", []byte(previousVersion), 0755)
+			if err != nil {
+				return err
+			}
+		}
+		if components != nil {
+			for _, kv := range components.keyValues() {
+				err = ioutil.WriteFile(kv[0], []byte(kv[1]), 0755)
+				if err != nil {
+					return err
+				}
 			}
-			return jsPackage.Version, nil
 		}
+	}
 
-	case pomxml:
-		pomFile := filepath.Join(o.Dir, pomxml)
-		p, err := ioutil.ReadFile(pomFile)
+	if o.EnvFile != "" {
+		err = writeEnvFile(o.EnvFile, o.NewVersion, previousVersion, tag, components)
 		if err != nil {
-			return "", err
+			return err
 		}
+	}
 
-		if o.Verbose {
-			log.Infof("found pom.xml\n")
+	if o.PrintPrevious {
+		log.Infof("previous version %s new version %s\n", previousVersion, o.NewVersion)
+	}
+
+	// if filename flag set and recognised then update version
+	updatedFile := o.Filename != "" && !alreadyTagged
+	if updatedFile {
+		err = o.setVersion()
+		if err == errReadOnlyVersionFile {
+			log.Infof("updating the version of %s is not supported so it is not updated\n", o.Filename)
+			updatedFile = false
+		} else if err != nil {
+			return err
 		}
-		var project Project
-		xml.Unmarshal(p, &project)
-		if project.Version != "" {
-			if o.Verbose {
-				log.Infof("existing version %s\n", project.Version)
-			}
-			return project.Version, nil
+	}
+
+	updatedChangelog := o.UpdateChangelog && !alreadyTagged
+	if updatedChangelog {
+		err = o.updateChangelog()
+		if err != nil {
+			return err
 		}
+	}
 
-	case makefile:
-		makefile := filepath.Join(o.Dir, makefile)
-		m, err := ioutil.ReadFile(makefile)
+	// commit any updated files as the release commit
+	if updatedFile || updatedChangelog {
+		err = o.commitVersion(fmt.Sprintf("Release %s", o.NewVersion))
 		if err != nil {
-			return "", err
+			return err
 		}
+	}
 
-		if o.Verbose {
-			log.Infof("found Makefile\n")
-		}
-		scanner := bufio.NewScanner(strings.NewReader(string(m)))
-		for scanner.Scan() {
-			if strings.HasPrefix(scanner.Text(), "VERSION") || strings.HasPrefix(scanner.Text(), "VERSION ") || strings.HasPrefix(scanner.Text(), "VERSION:") || strings.HasPrefix(scanner.Text(), "VERSION=") {
-				parts := strings.Split(scanner.Text(), "=")
-
-				v := strings.TrimSpace(parts[1])
-				if v != "" {
-					if o.Verbose {
-						log.Infof("existing Makefile version %s\n", v)
-					}
-					return v, nil
-				}
-			}
+	// if tag set then tag it
+	if tagRelease {
+		err = o.tagVersion(tag)
+		if err != nil {
+			return err
 		}
-	default:
-		return "", fmt.Errorf("no recognised file to obtain current version from")
 	}
 
-	return "", fmt.Errorf("cannot find version for file %s\n", o.Filename)
+	if o.Quiet {
+		_, err = fmt.Fprintln(o.Out, o.NewVersion)
+		return err
+	}
+	return nil
 }
 
-func (o *StepNextVersionOptions) getLatestTag() (string, error) {
-	// if repo isn't provided by flags fall back to using current repo if run from a git project
-	var versionsRaw []string
-
-	err := o.runCommand("git", "fetch", "--tags", "-v")
-	if err != nil {
-		return "", fmt.Errorf("error fetching tags: %v", err)
+// writeVersion writes the given version to the version file and commits it, for a version worked out elsewhere
+func (o *StepNextVersionOptions) writeVersion() error {
+	if o.NewVersion == "" {
+		return versionErrorf(errCodeInvalidFlag, "please specify the version to write with the flag version")
+	}
+	if o.Filename == "" {
+		return versionErrorf(errCodeNoFile, "please specify the file to write the version to with the flag filename")
+	}
+	if o.Tag {
+		return versionErrorf(errCodeInvalidFlag, "the flag write-only only writes the version so cannot be used with the flag tag")
+	}
+	if !o.AllowDirty {
+		err := o.verifyClean()
+		if err != nil {
+			return err
+		}
+	}
+	err := o.setVersion()
+	if err == errReadOnlyVersionFile {
+		return versionErrorf(errCodeUnsupportedFile, "updating the version of %s is not supported", o.Filename)
 	}
-	out, err := o.getCommandOutput("", "git", "tag")
 	if err != nil {
-		return "", err
+		return err
 	}
-	str := strings.TrimSuffix(string(out), "\n")
-	tags := strings.Split(str, "\n")
+	return o.commitVersion(fmt.Sprintf("Release %s", o.NewVersion))
+}
 
-	if len(tags) == 0 {
-		// if no current flags exist then lets start at 0.0.0
-		return "0.0.0", fmt.Errorf("no existing tags found")
+// tagVersion creates and pushes the git tag for the new version
+func (o *StepNextVersionOptions) tagVersion(tag string) error {
+	// a rerun pipeline would otherwise give the same commit a second version
+	headTags, err := o.headVersionTags()
+	if err != nil {
+		return err
 	}
-
-	// build an array of all the tags
-	versionsRaw = make([]string, len(tags))
-	for i, tag := range tags {
-		if o.Verbose {
-			log.Infof("found tag %s\n", tag)
-		}
-		tag = strings.TrimPrefix(tag, "v")
-		if tag != "" {
-			versionsRaw[i] = tag
+	if len(headTags) > 0 {
+		if !o.AllowRetag {
+			return versionErrorf(errCodeHeadTagged, "HEAD is already tagged with %s so not tagging it with %s as well, use the flag allow-retag to tag it anyway", strings.Join(headTags, ", "), tag)
 		}
+		log.Warnf("HEAD is already tagged with %s but tagging it with %s as well\n", strings.Join(headTags, ", "), tag)
 	}
 
-	// turn the array into a new collection of versions that we can sort
-	var versions []*version.Version
-	for _, raw := range versionsRaw {
-		v, _ := version.NewVersion(raw)
-		if v != nil {
-			versions = append(versions, v)
+	if o.PreTagHook != "" {
+		err = o.runTagHook(o.PreTagHook, tag)
+		if err != nil {
+			return versionErrorf(errCodeHookFailed, "pre tag hook failed so not tagging version %s: %v", o.NewVersion, err)
 		}
 	}
 
-	if len(versions) == 0 {
-		// if no current flags exist then lets start at 0.0.0
-		return "0.0.0", fmt.Errorf("no existing tags found")
+	message, err := o.tagMessage()
+	if err != nil {
+		return err
 	}
 
-	// return the latest tag
-	col := version.Collection(versions)
-	if o.Verbose {
-		log.Infof("version collection %v\n", col)
+	tagOptions := StepTagOptions{
+		Flags: StepTagFlags{
+			Version: o.NewVersion,
+			Tag:     tag,
+			NoPush:  o.NoPush,
+			Quiet:   o.Quiet,
+			Message: message,
+		},
+		StepOptions: o.StepOptions,
+	}
+	err = tagOptions.Run()
+	if err != nil || o.PostTagHook == "" {
+		return err
 	}
 
-	sort.Sort(col)
-	latest := len(versions)
-	if versions[latest-1] == nil {
-		return "0.0.0", fmt.Errorf("no existing tags found")
+	err = o.runTagHook(o.PostTagHook, tag)
+	if err == nil {
+		return nil
 	}
-	return versions[latest-1].String(), nil
+	if !o.UndoTagOnPostHookFailure {
+		return versionErrorf(errCodeHookFailed, "post tag hook failed after tagging version %s with %s which has been kept: %v", o.NewVersion, tag, err)
+	}
+	undoErr := o.deleteTag(tag)
+	if undoErr != nil {
+		return versionErrorf(errCodeHookFailed, "post tag hook failed after tagging version %s: %v and failed to delete the tag %s: %v", o.NewVersion, err, tag, undoErr)
+	}
+	return versionErrorf(errCodeHookFailed, "post tag hook failed so deleted the tag %s of version %s: %v", tag, o.NewVersion, err)
 }
 
-func (o *StepNextVersionOptions) getNewVersionFromTag() (string, error) {
+// deleteTag deletes the tag locally and, unless it was not pushed, from the remote origin
+func (o *StepNextVersionOptions) deleteTag(tag string) error {
+	if !o.NoPush {
+		_, err := o.getCommandOutput("", "git", "push", "--delete", "origin", tag)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := o.getCommandOutput("", "git", "tag", "--delete", tag)
+	return err
+}
 
-	// get the latest github tag
-	tag, err := o.getLatestTag()
-	if err != nil && tag == "" {
+// printDryRun prints the new version and the tag which would be created without changing anything
+func (o *StepNextVersionOptions) printDryRun() error {
+	_, err := fmt.Fprintf(o.Out, "version: %s\n", o.NewVersion)
+	if err != nil || !o.Tag {
+		return err
+	}
+	tag, err := o.tagName(time.Now())
+	if err != nil {
+		return err
+	}
+	message, err := o.tagMessage()
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		// the default message of step tag
+		message = fmt.Sprintf("release %s", o.NewVersion)
+	}
+	_, err = fmt.Fprintf(o.Out, "tag: %s\nmessage:\n%s\n", tag, message)
+	return err
+}
+
+// tagMessage returns the message of the tag of the new version, or an empty string for the default message
+func (o *StepNextVersionOptions) tagMessage() (string, error) {
+	if !o.TagAnnotationFromChangelog {
+		return "", nil
+	}
+	return o.tagAnnotation()
+}
+
+// tagAnnotation returns the changelog of the new version from the CHANGELOG.md, or if it doesn't have one the
+// subjects of the commits since the previous tag
+func (o *StepNextVersionOptions) tagAnnotation() (string, error) {
+	title := fmt.Sprintf("Release %s", o.NewVersion)
+	b, err := ioutil.ReadFile(filepath.Join(o.Dir, changelogmd))
+	if err != nil && !os.IsNotExist(err) {
 		return "", err
 	}
+	if notes := changelogSection(string(b), o.NewVersion); notes != "" {
+		return title + "\n\n" + notes, nil
+	}
 
-	sv, err := semver.Parse(tag)
+	latest, err := o.getBaseTag()
+	if err != nil && latest == "" {
+		return "", err
+	}
+	previous, err := o.versionTag(latest)
 	if err != nil {
 		return "", err
 	}
-
-	majorVersion := sv.Major
-	minorVersion := sv.Minor
-	patchVersion := sv.Patch + 1
-
-	// check if major or minor version has been changed
-	baseVersion, err := o.getVersion()
+	messages, err := o.commitMessagesSince(previous)
 	if err != nil {
 		return "", err
 	}
-
-	// first use go-version to turn into a proper version, this handles 1.0-SNAPSHOT which semver doesn't
-	baseMajorVersion := uint64(0)
-	baseMinorVersion := uint64(0)
-	basePatchVersion := uint64(0)
-
-	if baseVersion != "" {
-		tmpVersion, err := version.NewVersion(baseVersion)
-		if err != nil {
-			return "", err
-		}
-		bsv, err := semver.New(tmpVersion.String())
-		if err != nil {
-			return "", err
+	lines := []string{}
+	for _, message := range messages {
+		subject := firstLine([]byte(message))
+		if subject != title {
+			lines = append(lines, "* "+subject)
 		}
-		baseMajorVersion = bsv.Major
-		baseMinorVersion = bsv.Minor
-		basePatchVersion = bsv.Patch
 	}
+	if len(lines) == 0 {
+		return title, nil
+	}
+	return title + "\n\n" + strings.Join(lines, "\n"), nil
+}
 
-	if baseMajorVersion > majorVersion ||
-		(baseMajorVersion == majorVersion &&
-			(baseMinorVersion > minorVersion) || (baseMinorVersion == minorVersion && basePatchVersion > patchVersion)) {
-		majorVersion = baseMajorVersion
-		minorVersion = baseMinorVersion
-		patchVersion = basePatchVersion
+// changelogSection returns the text under the heading of the version in a changelog, up to the next heading
+func changelogSection(text string, newVersion string) string {
+	heading := regexp.MustCompile(`(?m)^## \[?` + regexp.QuoteMeta(newVersion) + `\]?([ \t].*)?$`)
+	loc := heading.FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+	section := text[loc[1]:]
+	next := regexp.MustCompile(`(?m)^## `).FindStringIndex(section)
+	if next != nil {
+		section = section[:next[0]]
 	}
+	return strings.TrimSpace(section)
+}
 
-	return fmt.Sprintf("%d.%d.%d", majorVersion, minorVersion, patchVersion), nil
+// VersionComponents are the parts of a semantic version, e.g. to produce docker tags 1, 1.2 and 1.2.3
+type VersionComponents struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
 }
-func (o *StepNextVersionOptions) setVersion() error {
-	var err error
-	var matchField string
-	var regex *regexp.Regexp
-	filename := filepath.Join(o.Dir, o.Filename)
-	b, err := ioutil.ReadFile(filename)
+
+// parseVersionComponents splits the version into its semantic version components
+func parseVersionComponents(v string) (*VersionComponents, error) {
+	sv, err := toSemver(v)
 	if err != nil {
-		return err
+		return nil, versionErrorf(errCodeParse, "failed to parse the components of version %s: %v", v, err)
 	}
-	switch o.Filename {
-	case packagejson:
-		regex = regexp.MustCompile(`[0-9][0-9]{0,2}.[0-9][0-9]{0,2}(.[0-9][0-9]{0,2})?(.[0-9][0-9]{0,2})?(-development)?`)
-		matchField = "\"version\": \""
-
-	case chartyaml:
-		regex = regexp.MustCompile(`[0-9][0-9]{0,2}.[0-9][0-9]{0,2}(.[0-9][0-9]{0,2})?(.[0-9][0-9]{0,2})?(-.*)?`)
-		matchField = "version: "
-
-	default:
-		return fmt.Errorf("unrecognised filename %s, supported files are %s %s", o.Filename, packagejson, chartyaml)
+	pre := []string{}
+	for _, p := range sv.Pre {
+		pre = append(pre, p.String())
 	}
+	return &VersionComponents{
+		Major:      sv.Major,
+		Minor:      sv.Minor,
+		Patch:      sv.Patch,
+		Prerelease: strings.Join(pre, "."),
+	}, nil
+}
 
-	lines := strings.Split(string(b), "\n")
+// keyValues returns the components in order as the names of the files and env entries they are written to
+func (c *VersionComponents) keyValues() [][]string {
+	return [][]string{
+		{"MAJOR", strconv.FormatUint(c.Major, 10)},
+		{"MINOR", strconv.FormatUint(c.Minor, 10)},
+		{"PATCH", strconv.FormatUint(c.Patch, 10)},
+		{"PRERELEASE", c.Prerelease},
+	}
+}
 
-	for i, line := range lines {
-		if strings.Contains(line, matchField) {
-			lines[i] = regex.ReplaceAllString(line, o.NewVersion)
-		} else {
-			lines[i] = line
+// writeEnvFile writes the versions and tag as KEY=value lines which can be sourced by any shell, along with the
+// version components if they are given
+func writeEnvFile(filename string, newVersion string, previousVersion string, tag string, components *VersionComponents) error {
+	text := fmt.Sprintf("VERSION=%s\nPREVIOUS_VERSION=%s\nTAG=%s\n",
+		shellQuote(newVersion), shellQuote(previousVersion), shellQuote(tag))
+	if components != nil {
+		for _, kv := range components.keyValues() {
+			text += fmt.Sprintf("%s=%s\n", kv[0], shellQuote(kv[1]))
 		}
 	}
-	output := strings.Join(lines, "\n")
-	err = ioutil.WriteFile(filename, []byte(output), 0644)
+	err := ioutil.WriteFile(filename, []byte(text), util.DefaultWritePermissions)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to write the env file %s: %v", filename, err)
 	}
+	return nil
+}
 
-	err = gits.GitAdd(o.Dir, o.Filename)
-	if err != nil {
-		return err
+// shellQuote single quotes the value if it contains characters a shell would interpret
+func shellQuote(value string) string {
+	if shellSafeRegex.MatchString(value) {
+		return value
 	}
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
 
-	err = gits.GitCommitDir(o.Dir, fmt.Sprintf("Release %s", o.NewVersion))
-	if err != nil {
-		return err
+// generatedFiles are written by this step so don't count as uncommitted changes
+var generatedFiles = map[string]bool{
+	"VERSION":          true,
+	"PREVIOUS_VERSION": true,
+	"MAJOR":            true,
+	"MINOR":            true,
+	"PATCH":            true,
+	"PRERELEASE":       true,
+}
+
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_.+/:@%=,-]*$`)
+
+var channelRegex = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+var invalidPrereleaseRegex = regexp.MustCompile(`[^0-9a-z-]+`)
+
+// branchChannel returns the current git branch as a prerelease identifier, falling back to $BRANCH_NAME when the
+// HEAD is detached as it is in many CI builds
+func (o *StepNextVersionOptions) branchChannel() (string, error) {
+	branch, err := o.getCommandOutput("", "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	branch = strings.TrimSpace(branch)
+	if branch == "HEAD" {
+		branch = os.Getenv("BRANCH_NAME")
+	}
+	channel := prereleaseIdentifier(branch)
+	if channel == "" {
+		return "", versionErrorf(errCodeInvalidFlag, "cannot work out the prerelease from the branch %q, HEAD is detached and $BRANCH_NAME is not set", branch)
+	}
+	return channel, nil
+}
+
+// prereleaseIdentifier sanitizes a branch name into a semantic version prerelease identifier, which can only contain
+// alphanumerics and hyphens, so release/1.3 becomes release-1-3
+func prereleaseIdentifier(branch string) string {
+	return strings.Trim(invalidPrereleaseRegex.ReplaceAllString(strings.ToLower(branch), "-"), "-")
+}
+
+// previewVersion returns the version of a pull request preview build, e.g. 1.2.4-pr.23.abc1234
+func (o *StepNextVersionOptions) previewVersion(newVersion string) (string, error) {
+	pr := o.PullRequest
+	if pr == "" {
+		pr = os.Getenv("BRANCH_NAME")
+	}
+	pr = strings.TrimPrefix(pr, "PR-")
+	if _, err := strconv.Atoi(pr); err != nil {
+		return "", versionErrorf(errCodeInvalidFlag, "no pull request number for the preview version, use the flag pr or set $BRANCH_NAME to PR-<number>")
+	}
+	sha, err := o.getCommandOutput("", "git", "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-pr%s%s.%s", newVersion, o.prereleaseSeparator(), pr, strings.TrimSpace(sha)), nil
+}
+
+// validSeparators are the values of the flags prerelease-separator and metadata-separator, where none joins without one
+var validSeparators = []string{".", "-", "none"}
+
+func isValidSeparator(separator string) bool {
+	return separator == "" || util.StringArrayIndex(validSeparators, separator) >= 0
+}
+
+// joinSeparator returns the separator for the value of a separator flag, which defaults to a dot
+func joinSeparator(separator string) string {
+	switch separator {
+	case "":
+		return "."
+	case "none":
+		return ""
+	default:
+		return separator
+	}
+}
+
+// prereleaseSeparator returns the separator between the channel and the prerelease number
+func (o *StepNextVersionOptions) prereleaseSeparator() string {
+	return joinSeparator(o.PrereleaseSeparator)
+}
+
+// buildMetadataVersion returns the version with the build metadata label and $BUILD_NUMBER appended
+func (o *StepNextVersionOptions) buildMetadataVersion(newVersion string) string {
+	metadata := o.BuildMetadata
+	if buildNumber := os.Getenv("BUILD_NUMBER"); buildNumber != "" {
+		metadata += joinSeparator(o.MetadataSeparator) + buildNumber
+	}
+	return newVersion + "+" + metadata
+}
+
+// isPrereleaseChannel returns true if releasing to a channel other than the stable 'latest' channel
+func (o *StepNextVersionOptions) isPrereleaseChannel() bool {
+	return o.Channel != "" && o.Channel != "latest"
+}
+
+// channelVersion returns the next prerelease of the version for the channel, e.g. 1.2.4-beta.3 if 1.2.4-beta.2 is
+// the highest existing tag for the beta channel
+func (o *StepNextVersionOptions) channelVersion(newVersion string) (string, error) {
+	prefix := o.tagPrefix() + newVersion + "-" + o.Channel + o.prereleaseSeparator()
+	out, err := o.getCommandOutput("", "git", "tag", "--list", prefix+"*")
+	if err != nil {
+		return "", err
+	}
+	next := 1
+	for _, tag := range strings.Split(out, "\n") {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(tag), prefix))
+		if err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	return fmt.Sprintf("%s-%s%s%d", newVersion, o.Channel, o.prereleaseSeparator(), next), nil
+}
+
+// prereleaseOnlyVersion returns the next prerelease of the channel without changing the major, minor or patch version,
+// which are those of the version file or else of the latest tag of the channel. Only if there is neither is the next
+// version worked out from the tags as usual. The latest tag of the channel is returned as the previous version
+func (o *StepNextVersionOptions) prereleaseOnlyVersion() (string, string, error) {
+	latest, err := o.latestChannelVersion()
+	if err != nil {
+		return "", "", err
+	}
+	fileVersion, err := o.getVersion()
+	if err != nil {
+		return "", "", err
+	}
+
+	core := ""
+	if fileVersion != "" {
+		sv, err := toSemver(fileVersion)
+		if err != nil {
+			return "", "", versionErrorf(errCodeParse, "invalid version %s in %s: %v", fileVersion, o.Filename, err)
+		}
+		core = fmt.Sprintf("%d.%d.%d", sv.Major, sv.Minor, sv.Patch)
+	} else if latest != nil {
+		core = fmt.Sprintf("%d.%d.%d", latest.Major, latest.Minor, latest.Patch)
+	} else {
+		core, _, err = o.getNewVersionFromTag()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	newVersion, err := o.channelVersion(core)
+	if err != nil {
+		return "", "", err
+	}
+	previous := ""
+	if latest != nil {
+		previous = latest.String()
+	}
+	return newVersion, previous, nil
+}
+
+// latestChannelVersion returns the highest version of the tags of the channel or nil if there are none
+func (o *StepNextVersionOptions) latestChannelVersion() (*semver.Version, error) {
+	prefix := o.tagPrefix()
+	channel := "-" + o.Channel + o.prereleaseSeparator()
+	out, err := o.getCommandOutput("", "git", "tag", "--list", prefix+"*"+channel+"*")
+	if err != nil {
+		return nil, err
+	}
+	var latest *semver.Version
+	var latestCore semver.Version
+	latestNumber := 0
+	for _, tag := range strings.Split(out, "\n") {
+		v := strings.TrimPrefix(strings.TrimSpace(tag), prefix)
+		idx := strings.Index(v, channel)
+		if idx < 0 {
+			continue
+		}
+		core, err := semver.Parse(v[:idx])
+		if err != nil || len(core.Pre) > 0 {
+			continue
+		}
+		n, err := strconv.Atoi(v[idx+len(channel):])
+		if err != nil {
+			continue
+		}
+		sv, err := semver.Parse(v)
+		if err != nil {
+			continue
+		}
+		if latest == nil || core.GT(latestCore) || (core.EQ(latestCore) && n > latestNumber) {
+			latest = &sv
+			latestCore = core
+			latestNumber = n
+		}
+	}
+	return latest, nil
+}
+
+// tagPrefix returns the prefix of the version in the names of the tags which are created
+func (o *StepNextVersionOptions) tagPrefix() string {
+	if o.TagPrefix != "" {
+		return o.TagPrefix
+	}
+	if o.NoVTag {
+		return ""
+	}
+	return "v"
+}
+
+// TagFormatData is the data available to the --tag-format and --write-template templates
+type TagFormatData struct {
+	Version string
+	Major   uint64
+	Minor   uint64
+	Patch   uint64
+	Year    string
+	Month   string
+	Day     string
+}
+
+// tagName returns the name of the tag for the new version, using the tag format template if one is specified
+func (o *StepNextVersionOptions) tagName(now time.Time) (string, error) {
+	if o.TagFormat == "" {
+		return o.tagPrefix() + o.NewVersion, nil
+	}
+	text, err := executeVersionTemplate(o.TagFormat, o.NewVersion, now)
+	if err != nil {
+		return "", versionErrorf(errCodeInvalidFlag, "invalid tag-format %s: %v", o.TagFormat, err)
+	}
+	tag := strings.TrimSpace(text)
+	if tag == "" {
+		return "", versionErrorf(errCodeInvalidFlag, "the tag-format %s results in an empty tag", o.TagFormat)
+	}
+	return tag, nil
+}
+
+// tagVersionParser returns a function returning the version of a tag of this component or an empty string for any
+// other tag, so tags created with the tag format or the tag prefix are recognised as versions
+func (o *StepNextVersionOptions) tagVersionParser() (func(tag string) string, error) {
+	if o.TagFormat != "" {
+		regex, err := tagFormatRegex(o.TagFormat)
+		if err != nil {
+			return nil, versionErrorf(errCodeInvalidFlag, "invalid tag-format %s: %v", o.TagFormat, err)
+		}
+		return func(tag string) string {
+			return tagFormatVersion(regex, tag)
+		}, nil
+	}
+	return func(tag string) string {
+		if o.TagPrefix != "" {
+			if !strings.HasPrefix(tag, o.TagPrefix) {
+				// the tag of another component
+				return ""
+			}
+			return strings.TrimPrefix(tag, o.TagPrefix)
+		}
+		// both v1.2.3 and bare 1.2.3 tags are versions whichever kind of tag is created
+		return strings.TrimPrefix(tag, "v")
+	}, nil
+}
+
+// tagFormatFields are the patterns of the TagFormatData fields when matching the tags created with a tag format
+var tagFormatFields = map[string]string{
+	"Version": `\S+?`,
+	"Major":   `\d+`,
+	"Minor":   `\d+`,
+	"Patch":   `\d+`,
+	"Year":    `\d{4}`,
+	"Month":   `\d{2}`,
+	"Day":     `\d{2}`,
+}
+
+// tagFormatRegex returns a regular expression matching the tags created with the tag format template, capturing
+// each field of the TagFormatData the template uses by its name
+func tagFormatRegex(format string) (*regexp.Regexp, error) {
+	tmpl, err := template.New("tag").Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	captured := map[string]bool{}
+	pattern := ""
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			pattern += regexp.QuoteMeta(string(n.Text))
+		case *parse.ActionNode:
+			pattern += tagFormatFieldPattern(n, captured)
+		default:
+			pattern += `.*?`
+		}
+	}
+	return regexp.Compile(`^\s*` + pattern + `\s*$`)
+}
+
+// tagFormatFieldPattern returns the pattern of an action of a tag format template, capturing the first use of a field
+func tagFormatFieldPattern(n *parse.ActionNode, captured map[string]bool) string {
+	if len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+		return `.*?`
+	}
+	field, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return `.*?`
+	}
+	name := field.Ident[0]
+	value, ok := tagFormatFields[name]
+	if !ok {
+		return `.*?`
+	}
+	if captured[name] {
+		return value
+	}
+	captured[name] = true
+	return `(?P<` + name + `>` + value + `)`
+}
+
+// tagFormatVersion returns the version of a tag matching the tag format regex or an empty string if it doesn't match
+func tagFormatVersion(regex *regexp.Regexp, tag string) string {
+	matched := regex.FindStringSubmatch(tag)
+	if matched == nil {
+		return ""
+	}
+	fields := map[string]string{"Minor": "0", "Patch": "0"}
+	for i, name := range regex.SubexpNames() {
+		if name != "" {
+			fields[name] = matched[i]
+		}
+	}
+	if v, ok := fields["Version"]; ok {
+		return v
+	}
+	if _, ok := fields["Major"]; !ok {
+		// a tag format without the version or major can't be parsed
+		return ""
+	}
+	return fields["Major"] + "." + fields["Minor"] + "." + fields["Patch"]
+}
+
+// executeVersionTemplate executes the template with the TagFormatData of the version
+func executeVersionTemplate(text string, newVersion string, now time.Time) (string, error) {
+	tmpl, err := template.New("version").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	sv, err := toSemver(newVersion)
+	if err != nil {
+		return "", err
+	}
+	data := TagFormatData{
+		Version: newVersion,
+		Major:   sv.Major,
+		Minor:   sv.Minor,
+		Patch:   sv.Patch,
+		Year:    now.Format("2006"),
+		Month:   now.Format("01"),
+		Day:     now.Format("02"),
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// tagExists returns true if the git tag already exists
+func (o *StepNextVersionOptions) tagExists(tag string) (bool, error) {
+	out, err := o.getCommandOutput("", "git", "tag", "--list", tag)
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+// gets the version from a source file
+func (o *StepNextVersionOptions) getVersion() (string, error) {
+	if o.UseGitTagOnly {
+		return "", nil
+	}
+	if o.Filename == "" {
+		// try and work out
+		return "", versionErrorf(errCodeNoFile, "no filename flag set to work out next semantic version.  choose %s or set the flag use-git-tag-only", strings.Join(supportedVersionFiles(), ", "))
+	}
+
+	versionFile := o.versionFileFor(o.Filename)
+	if versionFile == nil {
+		return "", versionErrorf(errCodeUnsupportedFile, "no recognised file to obtain current version from")
+	}
+	file, err := o.versionFile()
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(o.Dir, file))
+	if err != nil {
+		if os.IsNotExist(err) && o.FallbackToTag {
+			log.Warnf("%s does not exist so only git tags will be used\n", o.Filename)
+			return "", nil
+		}
+		if os.IsNotExist(err) {
+			return "", o.versionFileNotFound()
+		}
+		return "", err
+	}
+
+	if o.Verbose {
+		log.Infof("found %s\n", o.Filename)
+	}
+	v, err := versionFile.Read(b)
+	if err == errNoVersion {
+		if o.FallbackToTag {
+			log.Warnf("no version found in %s so only git tags will be used\n", o.Filename)
+			return "", nil
+		}
+		return "", versionErrorf(errCodeNoVersion, "cannot find version for file %s\n", o.Filename)
+	}
+	if err != nil {
+		return "", versionErrorf(errCodeParse, "failed to read the version from %s: %v", o.Filename, err)
+	}
+	if o.Verbose && v != "" {
+		log.Infof("existing version %s\n", v)
+	}
+	return v, nil
+}
+
+// versionFileNotFound returns an actionable error for a version file which doesn't exist
+func (o *StepNextVersionOptions) versionFileNotFound() error {
+	dir := o.Dir
+	if dir == "" {
+		dir = "the current directory"
+	}
+	file, err := o.versionFile()
+	if err != nil {
+		file = o.Filename
+	}
+	return versionErrorf(errCodeMissingFile, "version file %s not found in %s, to version from the git tags alone use the flag use-git-tag-only or fallback-to-tag", file, dir)
+}
+
+// runTagHook runs the shell command hook with the new version and tag available as the $VERSION and $TAG environment
+// variables of the hook only
+func (o *StepNextVersionOptions) runTagHook(hook string, tag string) error {
+	e := exec.Command("sh", "-c", hook)
+	e.Env = append(os.Environ(), "PATH="+util.PathWithBinary(), "VERSION="+o.NewVersion, "TAG="+tag)
+	e.Stdout = o.Out
+	e.Stderr = o.Err
+	err := e.Run()
+	if err != nil {
+		log.Errorf("Error: Command failed  sh -c %s\n", hook)
+	}
+	return err
+}
+
+// verifyClean returns an error if the git working tree has uncommitted changes, ignoring the VERSION files this
+// step writes itself, so an inconsistent state is not released
+func (o *StepNextVersionOptions) verifyClean() error {
+	out, err := o.getCommandOutput("", "git", "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	changes := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		path := strings.TrimSpace(line[strings.Index(line, " ")+1:])
+		if generatedFiles[path] {
+			continue
+		}
+		changes = append(changes, path)
+	}
+	if len(changes) > 0 {
+		return versionErrorf(errCodeDirty, "the git working tree has uncommitted changes to %s, please commit them or use the flag allow-dirty", strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+// verifyHasCommits returns a clear error if the current git repository does not have any commits yet
+func (o *StepNextVersionOptions) verifyHasCommits() error {
+	out, err := o.getCommandOutput("", "git", "rev-list", "--count", "HEAD")
+	if err != nil {
+		if strings.Contains(err.Error(), "unknown revision") || strings.Contains(err.Error(), "ambiguous argument 'HEAD'") {
+			return versionErrorf(errCodeNoCommits, "no commits to tag, please commit to the repository before running this step")
+		}
+		return err
+	}
+	if out == "0" {
+		return versionErrorf(errCodeNoCommits, "no commits to tag, please commit to the repository before running this step")
+	}
+	return nil
+}
+
+// versionFile returns the path relative to the dir of the file containing the version, resolving any workspace
+func (o *StepNextVersionOptions) versionFile() (string, error) {
+	if o.Filename == packagejson && o.Workspace != "" {
+		return filepath.Join("packages", o.Workspace, packagejson), nil
+	}
+	if filepath.Base(o.Filename) == melosyaml {
+		return o.melosPackageFile()
+	}
+	return o.Filename, nil
+}
+
+// MelosWorkspace is the part of a melos.yaml listing the globs of the directories of the packages
+type MelosWorkspace struct {
+	Packages []string `yaml:"packages"`
+}
+
+// melosPackageFile returns the path of the pubspec.yaml of the package of a Melos workspace, which is searched for
+// in the package directories of the melos.yaml
+func (o *StepNextVersionOptions) melosPackageFile() (string, error) {
+	if o.Package == "" {
+		return "", versionErrorf(errCodeInvalidFlag, "please specify the package of the Melos workspace to version with the flag package")
+	}
+	root := filepath.Join(o.Dir, filepath.Dir(o.Filename))
+	b, err := ioutil.ReadFile(filepath.Join(o.Dir, o.Filename))
+	if err != nil {
+		return "", err
+	}
+	var workspace MelosWorkspace
+	err = yaml.Unmarshal(b, &workspace)
+	if err != nil {
+		return "", versionErrorf(errCodeParse, "failed to parse %s: %v", o.Filename, err)
+	}
+	if len(workspace.Packages) == 0 {
+		return "", versionErrorf(errCodeParse, "no packages listed in %s", o.Filename)
+	}
+
+	found := ""
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(info.Name(), ".") || info.Name() == "build") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != pubspecyaml {
+			return nil
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil || !matchesMelosPackages(workspace.Packages, filepath.ToSlash(dir)) {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := getYamlPathString(b, []string{"name"})
+		if err == nil && name == o.Package {
+			found = path
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if found == "" {
+		return "", versionErrorf(errCodeMissingFile, "no package %s found in the packages of %s", o.Package, o.Filename)
+	}
+	return filepath.Rel(o.Dir, found)
+}
+
+// matchesMelosPackages returns true if the directory matches one of the package globs of a melos.yaml, where a
+// trailing ** matches any directory below
+func matchesMelosPackages(globs []string, dir string) bool {
+	dirSegments := strings.Split(dir, "/")
+	for _, glob := range globs {
+		segments := strings.Split(strings.TrimSuffix(strings.TrimPrefix(glob, "./"), "/"), "/")
+		recursive := segments[len(segments)-1] == "**"
+		if recursive {
+			segments = segments[:len(segments)-1]
+		}
+		if len(dirSegments) < len(segments) || (!recursive && len(dirSegments) != len(segments)) {
+			continue
+		}
+		matched := true
+		for i, segment := range segments {
+			if ok, err := filepath.Match(segment, dirSegments[i]); err != nil || !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *StepNextVersionOptions) getLatestTag() (string, error) {
+	if o.TagSort == tagSortCreatorDate {
+		return o.getLatestCreatedTag()
+	}
+
+	// if repo isn't provided by flags fall back to using current repo if run from a git project
+	var versionsRaw []string
+
+	tags, err := o.listTags()
+	if err != nil {
+		return "", err
+	}
+
+	if len(tags) == 0 {
+		// if no current flags exist then lets start at 0.0.0
+		return "0.0.0", versionErrorf(errCodeNoTags, "no existing tags found")
+	}
+
+	tagVersion, err := o.tagVersionParser()
+	if err != nil {
+		return "", err
+	}
+
+	// build an array of all the tags
+	versionsRaw = make([]string, len(tags))
+	for i, tag := range tags {
+		if o.Verbose {
+			log.Infof("found tag %s\n", tag)
+		}
+		tag = tagVersion(tag)
+		if tag != "" {
+			versionsRaw[i] = tag
+		}
+	}
+
+	// turn the array into a new collection of versions that we can sort
+	var versions []*version.Version
+	for _, raw := range versionsRaw {
+		v, _ := version.NewVersion(raw)
+		if v != nil {
+			if v.Prerelease() != "" {
+				// the next version of every channel, including the stable one, is numbered from the latest stable
+				// release so the prereleases of a channel don't affect the numbering of the others
+				continue
+			}
+			versions = append(versions, v)
+		}
+	}
+
+	if len(versions) == 0 {
+		// if no current flags exist then lets start at 0.0.0
+		return "0.0.0", versionErrorf(errCodeNoTags, "no existing tags found")
+	}
+
+	// return the latest tag
+	col := version.Collection(versions)
+	if o.Verbose {
+		log.Infof("version collection %v\n", col)
+	}
+
+	sort.Sort(col)
+	latest := len(versions)
+	if versions[latest-1] == nil {
+		return "0.0.0", versionErrorf(errCodeNoTags, "no existing tags found")
+	}
+	return versions[latest-1].String(), nil
+}
+
+// getLatestCreatedTag returns the version of the most recently created tag, which need not be the highest version
+func (o *StepNextVersionOptions) getLatestCreatedTag() (string, error) {
+	err := o.fetchTags()
+	if err != nil {
+		return "", err
+	}
+	out, err := o.getCommandOutput("", "git", "tag", "--sort=-creatordate")
+	if err != nil {
+		return "", err
+	}
+	tagVersion, err := o.tagVersionParser()
+	if err != nil {
+		return "", err
+	}
+	for _, tag := range strings.Split(out, "\n") {
+		tag = strings.TrimSpace(tag)
+		if o.Verbose && tag != "" {
+			log.Infof("found tag %s\n", tag)
+		}
+		tag = tagVersion(tag)
+		v, err := version.NewVersion(tag)
+		if err != nil {
+			// not a version tag, e.g. a deployment marker
+			continue
+		}
+		if v.Prerelease() != "" {
+			// the next version of every channel, including the stable one, is numbered from the latest stable
+			// release so the prereleases of a channel don't affect the numbering of the others
+			continue
+		}
+		return tag, nil
+	}
+	return "0.0.0", versionErrorf(errCodeNoTags, "no existing tags found")
+}
+
+// listTags returns the names of the existing tags from the tags file if there is one or else from git
+func (o *StepNextVersionOptions) listTags() ([]string, error) {
+	if o.TagsFile != "" {
+		b, err := ioutil.ReadFile(o.TagsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the tags file %s: %v", o.TagsFile, err)
+		}
+		tags := []string{}
+		for _, line := range strings.Split(string(b), "\n") {
+			if tag := strings.TrimSpace(line); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		return tags, nil
+	}
+	err := o.fetchTags()
+	if err != nil {
+		return nil, err
+	}
+	out, err := o.getCommandOutput("", "git", "tag")
+	if err != nil {
+		return nil, err
+	}
+	str := strings.TrimSuffix(string(out), "\n")
+	return strings.Split(str, "\n"), nil
+}
+
+// fetchTags fetches the git tags, retrying with an exponential backoff on transient failures
+func (o *StepNextVersionOptions) fetchTags() error {
+	args := []string{"fetch", "--tags", "-v"}
+	if o.isShallowClone() {
+		if o.AutoUnshallow {
+			args = []string{"fetch", "--unshallow", "--tags", "-v"}
+		} else {
+			log.Warnf("the git clone is shallow so the tags may be incomplete, use the flag auto-unshallow to fetch the full history\n")
+		}
+	}
+	delay := time.Second
+	if o.FetchRetryDelay != "" {
+		d, err := time.ParseDuration(o.FetchRetryDelay)
+		if err != nil {
+			return versionErrorf(errCodeInvalidFlag, "invalid fetch-retry-delay %s: %v", o.FetchRetryDelay, err)
+		}
+		delay = d
+	}
+	for i := 0; ; i++ {
+		out, err := o.getCommandOutput("", "git", args...)
+		if err == nil {
+			if o.Verbose {
+				log.Infof("%s\n", out)
+			}
+			return nil
+		}
+		if i >= o.FetchRetries || isPermanentFetchError(err) {
+			return versionErrorf(errCodeFetchFailed, "error fetching tags: %v", err)
+		}
+		log.Warnf("failed to fetch tags, retrying in %s: %v\n", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isShallowClone returns true if the git repository is a shallow clone, such as those of CI systems which clone with
+// a fetch depth
+func (o *StepNextVersionOptions) isShallowClone() bool {
+	out, err := o.getCommandOutput("", "git", "rev-parse", "--is-shallow-repository")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// isPermanentFetchError returns true if retrying the git fetch cannot succeed, e.g. authentication failures
+func isPermanentFetchError(err error) bool {
+	permanentErrors := []string{
+		"Authentication failed",
+		"Permission denied",
+		"could not read Username",
+		"Repository not found",
+		"does not appear to be a git repository",
+		"No remote repository specified",
+		"not a git repository",
+	}
+	text := err.Error()
+	for _, e := range permanentErrors {
+		if strings.Contains(text, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// getNewVersionFromTag returns the new version along with the previous version it was worked out from
+func (o *StepNextVersionOptions) getNewVersionFromTag() (string, string, error) {
+
+	switch o.Base {
+	case "", baseTag:
+	case baseFile:
+		return o.getNewVersionFromFile()
+	default:
+		return "", "", versionErrorf(errCodeInvalidFlag, "unknown base %s, choose %s or %s", o.Base, baseTag, baseFile)
+	}
+
+	// get the latest github tag
+	tag, err := o.getBaseTag()
+	if err != nil && tag == "" {
+		return "", "", err
+	}
+
+	if o.IncrementBuildOnly {
+		newVersion, err := incrementBuildSegment(tag)
+		return newVersion, tag, err
+	}
+
+	sv, err := toSemver(tag)
+	if err != nil {
+		return "", "", versionErrorf(errCodeParse, "invalid tag version %s: %v", tag, err)
+	}
+
+	// check if major or minor version has been changed
+	baseVersion, err := o.getVersion()
+	if err != nil {
+		return "", "", err
+	}
+
+	var base *semver.Version
+	if baseVersion != "" {
+		bsv, err := toSemver(baseVersion)
+		if err != nil {
+			return "", "", err
+		}
+		base = &bsv
+
+		// rerunning on a commit which is already tagged with the file version gives the same version again
+		if bsv.Equals(sv) && !o.ForcePatchOnEqual {
+			tagged, err := o.headHasTag(o.tagPrefix() + tag)
+			if err != nil {
+				return "", "", err
+			}
+			if tagged {
+				log.Infof("HEAD is already tagged with the file version %s so reusing it, use the flag force-patch-on-equal to increment it\n", tag)
+				return fmt.Sprintf("%d.%d.%d", sv.Major, sv.Minor, sv.Patch), tag, nil
+			}
+		}
+	}
+
+	strategy, err := o.incrementStrategy(tag)
+	if err != nil {
+		return "", "", err
+	}
+	nv, err := strategy.NextVersion(sv, base)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%d.%d.%d", nv.Major, nv.Minor, nv.Patch), tag, nil
+}
+
+// incrementStrategy returns the IncrementStrategy selected with the flag strategy for the latest tag version
+func (o *StepNextVersionOptions) incrementStrategy(latest string) (IncrementStrategy, error) {
+	name := o.Strategy
+	if name == "" {
+		name = strategyPatch
+	}
+	factory := incrementStrategyFactories[name]
+	if factory == nil {
+		return nil, versionErrorf(errCodeInvalidFlag, "unknown strategy %s, choose %s", name, strings.Join(supportedIncrementStrategies(), ", "))
+	}
+	return factory(o, latest)
+}
+
+// nextVersionFromTag increments the patch of the latest tag version. If the base version from the version file is
+// ahead of the incremented tag it is used instead, otherwise when the base version is equal to or behind the tag the
+// incremented tag is used
+func nextVersionFromTag(tag semver.Version, base *semver.Version) semver.Version {
+	next := semver.Version{Major: tag.Major, Minor: tag.Minor, Patch: tag.Patch + 1}
+	if base != nil && base.Compare(next) > 0 {
+		return semver.Version{Major: base.Major, Minor: base.Minor, Patch: base.Patch}
+	}
+	return next
+}
+
+// versionUnchanged returns true if the new version is the same as the version of the latest tag
+func (o *StepNextVersionOptions) versionUnchanged() (bool, error) {
+	latest, err := o.getBaseTag()
+	if err != nil {
+		if latest != "" {
+			// there are no tags yet
+			return false, nil
+		}
+		return false, err
+	}
+	lv, err := toSemver(latest)
+	if err != nil {
+		return latest == o.NewVersion, nil
+	}
+	nv, err := toSemver(o.NewVersion)
+	if err != nil {
+		return false, nil
+	}
+	return nv.Equals(lv), nil
+}
+
+// headVersionTags returns the version tags of this component which point at the current HEAD commit
+func (o *StepNextVersionOptions) headVersionTags() ([]string, error) {
+	out, err := o.getCommandOutput("", "git", "tag", "--points-at", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	tagVersion, err := o.tagVersionParser()
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	for _, t := range strings.Split(out, "\n") {
+		t = strings.TrimSpace(t)
+		if _, err := version.NewVersion(tagVersion(t)); t != "" && err == nil {
+			tags = append(tags, t)
+		}
+	}
+	return tags, nil
+}
+
+// getBaseTag returns the version of the tag given by the flag since-tag or else the latest tag
+func (o *StepNextVersionOptions) getBaseTag() (string, error) {
+	if o.SinceTag == "" {
+		return o.getLatestTag()
+	}
+	exists, err := o.tagExists(o.SinceTag)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", versionErrorf(errCodeNoTags, "the since tag %s does not exist", o.SinceTag)
+	}
+	v := strings.TrimPrefix(o.SinceTag, o.tagPrefix())
+	if o.TagPrefix == "" {
+		v = strings.TrimPrefix(v, "v")
+	}
+	_, err = toSemver(v)
+	if err != nil {
+		return "", versionErrorf(errCodeParse, "the since tag %s is not a version: %v", o.SinceTag, err)
+	}
+	return v, nil
+}
+
+// headHasTag returns true if the current HEAD commit is tagged with the tag
+func (o *StepNextVersionOptions) headHasTag(tag string) (bool, error) {
+	out, err := o.getCommandOutput("", "git", "tag", "--points-at", "HEAD")
+	if err != nil {
+		return false, err
+	}
+	for _, t := range strings.Split(out, "\n") {
+		if strings.TrimSpace(t) == tag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getNewVersionFromFile increments the version in the file with the selected strategy, ignoring any git tags
+func (o *StepNextVersionOptions) getNewVersionFromFile() (string, string, error) {
+	baseVersion, err := o.getVersion()
+	if err != nil {
+		return "", "", err
+	}
+	if baseVersion == "" {
+		if o.FallbackToTag {
+			to := *o
+			to.Base = baseTag
+			return to.getNewVersionFromTag()
+		}
+		return "", "", versionErrorf(errCodeNoVersion, "no version found in file %s to use as the base version", o.Filename)
+	}
+
+	bsv, err := toSemver(baseVersion)
+	if err != nil {
+		return "", "", err
+	}
+	// ignoring tags the file version is the latest version so it is incremented by the selected strategy
+	strategy, err := o.incrementStrategy(baseVersion)
+	if err != nil {
+		return "", "", err
+	}
+	nv, err := strategy.NextVersion(bsv, nil)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%d.%d.%d", nv.Major, nv.Minor, nv.Patch), baseVersion, nil
+}
+
+// toSemver converts any version go-version accepts into a strict semantic version. go-version handles versions like
+// 1.0-SNAPSHOT which semver doesn't, any fourth segment is kept as build metadata and invalid identifiers are fixed up
+func toSemver(v string) (semver.Version, error) {
+	gv, err := version.NewVersion(strings.TrimSpace(v))
+	if err != nil {
+		return semver.Version{}, err
+	}
+	segments := gv.Segments64()
+	sv := semver.Version{
+		Major: uint64(segments[0]),
+		Minor: uint64(segments[1]),
+		Patch: uint64(segments[2]),
+	}
+	for _, id := range semverIdentifiers(gv.Prerelease()) {
+		pr, err := semver.NewPRVersion(id)
+		if err != nil {
+			// numeric identifiers must not have leading zeroes
+			id = strings.TrimLeft(id, "0")
+			if id == "" {
+				id = "0"
+			}
+			pr, err = semver.NewPRVersion(id)
+			if err != nil {
+				return semver.Version{}, err
+			}
+		}
+		sv.Pre = append(sv.Pre, pr)
+	}
+	for _, s := range segments[3:] {
+		sv.Build = append(sv.Build, fmt.Sprintf("%d", s))
+	}
+	sv.Build = append(sv.Build, semverIdentifiers(gv.Metadata())...)
+	return sv, sv.Validate()
+}
+
+// semverIdentifiers splits dot separated identifiers replacing any characters semver doesn't allow
+func semverIdentifiers(text string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(text, ".") {
+		id = strings.Replace(id, "~", "-", -1)
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// validateIncrement returns an error if the new version is not strictly greater than the latest version
+func validateIncrement(newVersion string, latest string) error {
+	nv, err := version.NewVersion(newVersion)
+	if err != nil {
+		return versionErrorf(errCodeParse, "invalid new version %s: %v", newVersion, err)
+	}
+	lv, err := version.NewVersion(latest)
+	if err != nil {
+		return versionErrorf(errCodeParse, "invalid latest version %s: %v", latest, err)
+	}
+	if !nv.GreaterThan(lv) {
+		return versionErrorf(errCodeValidation, "new version %s is not greater than the latest version %s", newVersion, latest)
+	}
+	return nil
+}
+
+// trimVersionPrefix removes a leading 'v' from a version such as v1.2.3
+func trimVersionPrefix(v string) string {
+	if len(v) > 1 && (v[0] == 'v' || v[0] == 'V') && v[1] >= '0' && v[1] <= '9' {
+		return v[1:]
+	}
+	return v
+}
+
+// validateMaxVersion returns an error if the new version is greater than the maximum version
+func validateMaxVersion(newVersion string, maxVersion string) error {
+	nv, err := toSemver(newVersion)
+	if err != nil {
+		return versionErrorf(errCodeParse, "invalid new version %s: %v", newVersion, err)
+	}
+	mv, err := toSemver(maxVersion)
+	if err != nil {
+		return versionErrorf(errCodeParse, "invalid max version %s: %v", maxVersion, err)
+	}
+	if nv.GT(mv) {
+		return versionErrorf(errCodeValidation, "new version %s is greater than the max version %s", newVersion, maxVersion)
+	}
+	return nil
+}
+
+// applyMinVersion returns the minimum version if the new version is less than it, otherwise the new version
+func applyMinVersion(newVersion string, minVersion string) (string, error) {
+	nv, err := toSemver(newVersion)
+	if err != nil {
+		return "", versionErrorf(errCodeParse, "invalid new version %s: %v", newVersion, err)
+	}
+	mv, err := toSemver(minVersion)
+	if err != nil {
+		return "", versionErrorf(errCodeParse, "invalid min version %s: %v", minVersion, err)
+	}
+	if nv.LT(mv) {
+		log.Infof("new version %s is less than the min version %s so using %s\n", newVersion, minVersion, minVersion)
+		return trimVersionPrefix(minVersion), nil
+	}
+	return newVersion, nil
+}
+
+// incrementBuildSegment increments only the fourth segment of a 4 part version, keeping the first three fixed
+func incrementBuildSegment(tag string) (string, error) {
+	v, err := version.NewVersion(tag)
+	if err != nil {
+		return "", err
+	}
+	segments := v.Segments()
+	if len(segments) != 4 {
+		return "", versionErrorf(errCodeParse, "cannot increment build segment of version %s as it does not have 4 segments", tag)
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", segments[0], segments[1], segments[2], segments[3]+1), nil
+}
+
+func (o *StepNextVersionOptions) setVersion() error {
+	versionFile := o.versionFileFor(o.Filename)
+	if versionFile == nil {
+		return versionErrorf(errCodeUnsupportedFile, "unrecognised filename %s, supported files are %s", o.Filename, strings.Join(supportedVersionFiles(), " "))
+	}
+	file, err := o.versionFile()
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(o.Dir, file)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) && o.FallbackToTag {
+			// the version only lives in the git tags
+			return nil
+		}
+		if os.IsNotExist(err) {
+			return o.versionFileNotFound()
+		}
+		return err
+	}
+	output, err := versionFile.Write(b, o.NewVersion)
+	if err == errReadOnlyVersionFile {
+		return err
+	}
+	if err != nil {
+		return versionErrorf(errCodeParse, "cannot update the version in %s: %v", o.Filename, err)
+	}
+	if bytes.Equal(b, output) {
+		// nothing to update
+		return nil
+	}
+
+	if o.ShowDiff {
+		log.Infof("%s", unifiedDiff(file, string(b), string(output)))
+	}
+
+	err = ioutil.WriteFile(filename, output, 0644)
+	if err != nil {
+		return err
+	}
+
+	return gits.GitAdd(o.Dir, file)
+}
+
+// unifiedDiff returns a unified diff of the lines of the old and new text with 3 lines of context
+func unifiedDiff(filename string, oldText string, newText string) string {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	// the longest common subsequence of the lines from each position to the end
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte
+		text string
+		i, j int
+	}
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	const context = 3
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- a/%s\n+++ b/%s\n", filename, filename)
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// extend the hunk while the changes are within twice the context of each other
+		end := start
+		for k := start; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k
+			} else if k-end > 2*context {
+				break
+			}
+		}
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context + 1
+		if to > len(lines) {
+			to = len(lines)
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				oldCount++
+			}
+			if l.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", lines[from].i+1, oldCount, lines[from].j+1, newCount)
+		for _, l := range lines[from:to] {
+			buf.WriteByte(l.op)
+			buf.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return buf.String()
+}
+
+// splitLines splits the text into lines keeping their line endings
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// updateChangelog adds a heading for the new version to the CHANGELOG.md, moving the unreleased changes under it
+func (o *StepNextVersionOptions) updateChangelog() error {
+	filename := filepath.Join(o.Dir, changelogmd)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	dateFormat := o.ChangelogDateFormat
+	if dateFormat == "" {
+		dateFormat = "2006-01-02"
+	}
+	output := addChangelogHeading(string(b), o.NewVersion, time.Now().Format(dateFormat))
+	err = ioutil.WriteFile(filename, []byte(output), 0644)
+	if err != nil {
+		return err
+	}
+	return gits.GitAdd(o.Dir, changelogmd)
+}
+
+// addChangelogHeading inserts the version heading replacing the Unreleased heading, which is kept empty above it.
+// If there is no Unreleased heading the version heading is added before the first release heading
+func addChangelogHeading(text string, newVersion string, date string) string {
+	heading := fmt.Sprintf("## [%s] - %s", newVersion, date)
+	unreleased := regexp.MustCompile(`(?mi)^## \[Unreleased\][ \t]*$`)
+	loc := unreleased.FindStringIndex(text)
+	if loc != nil {
+		return text[:loc[1]] + "\n\n" + heading + text[loc[1]:]
+	}
+	release := regexp.MustCompile(`(?m)^## `)
+	loc = release.FindStringIndex(text)
+	if loc != nil {
+		return text[:loc[0]] + heading + "\n\n" + text[loc[0]:]
+	}
+	return strings.TrimRight(text, "\n") + "\n\n" + heading + "\n"
+}
+
+// commitVersion commits the staged version changes, signing the commit if required
+func (o *StepNextVersionOptions) commitVersion(message string) error {
+	out, err := o.getCommandOutput(o.Dir, "git", "diff", "--cached", "--name-only")
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		// nothing was updated so there is nothing to commit
+		return nil
+	}
+	e := exec.Command("git", o.commitArgs(message)...)
+	e.Dir = o.Dir
+	e.Env = append(os.Environ(), o.commitAuthorEnv()...)
+	if o.Quiet {
+		// discard the output of git so only the new version is printed
+		out, err := e.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to run git commit in %s due to %s: %s", o.Dir, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	e.Stdout = o.Out
+	e.Stderr = o.Err
+	err = e.Run()
+	if err != nil {
+		return fmt.Errorf("failed to run git commit in %s due to %s", o.Dir, err)
 	}
 	return nil
 }
 
+// commitArgs returns the arguments of the git commit of the release commit
+func (o *StepNextVersionOptions) commitArgs(message string) []string {
+	if o.SignCommit {
+		return []string{"commit", "-S", "-m", message}
+	}
+	return []string{"commit", "-m", message}
+}
+
+// commitAuthorEnv returns the environment overriding the git identity of the release commit, which takes precedence
+// over both the git config and any identity the CI runner has already exported
+func (o *StepNextVersionOptions) commitAuthorEnv() []string {
+	env := []string{}
+	if o.CommitAuthorName != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+o.CommitAuthorName, "GIT_COMMITTER_NAME="+o.CommitAuthorName)
+	}
+	if o.CommitAuthorEmail != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+o.CommitAuthorEmail, "GIT_COMMITTER_EMAIL="+o.CommitAuthorEmail)
+	}
+	return env
+}
+
 func (o *StepNextVersionOptions) setPackageVersion(b []byte) error {
 	jsPackage := PackageJSON{}
 	err := json.Unmarshal(b, &jsPackage)
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+// VersionsConfig is the configuration of the independently versioned components of a monorepo, e.g. .jx-versions.yaml
+type VersionsConfig struct {
+	Components []VersionComponent `yaml:"components"`
+}
+
+// VersionComponent is a component of a monorepo which is versioned and tagged independently of the others
+type VersionComponent struct {
+	// Path is the directory of the component relative to the repository root
+	Path string `yaml:"path"`
+	// TagPrefix is prepended to the version to give the tag of the component, e.g. api-v
+	TagPrefix string `yaml:"tagPrefix"`
+	// Filename is the optional file in the component directory containing its version
+	Filename string `yaml:"filename,omitempty"`
+}
+
+// loadVersionsConfig loads and validates the monorepo versions configuration file
+func loadVersionsConfig(filename string) (*VersionsConfig, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the versions config %s: %v", filename, err)
+	}
+	config := &VersionsConfig{}
+	err = yaml.Unmarshal(b, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the versions config %s: %v", filename, err)
+	}
+	if len(config.Components) == 0 {
+		return nil, fmt.Errorf("no components found in the versions config %s", filename)
+	}
+	prefixes := map[string]string{}
+	for _, c := range config.Components {
+		if c.Path == "" {
+			return nil, fmt.Errorf("a component in the versions config %s has no path", filename)
+		}
+		if c.TagPrefix == "" {
+			return nil, fmt.Errorf("the component %s in the versions config %s has no tagPrefix", c.Path, filename)
+		}
+		if other, ok := prefixes[c.TagPrefix]; ok {
+			return nil, fmt.Errorf("the components %s and %s in the versions config %s have the same tagPrefix %s", other, c.Path, filename, c.TagPrefix)
+		}
+		prefixes[c.TagPrefix] = c.Path
+	}
+	return config, nil
+}
+
+// runComponents works out and applies the next version of each component in the versions config in turn
+func (o *StepNextVersionOptions) runComponents() error {
+	config, err := loadVersionsConfig(o.Config)
+	if err != nil {
+		return err
+	}
+	for _, c := range config.Components {
+		co := *o
+		co.Config = ""
+		co.Dir = filepath.Join(o.Dir, c.Path)
+		co.Filename = c.Filename
+		co.TagPrefix = c.TagPrefix
+		// a component without a version file is versioned by its git tags alone
+		co.UseGitTagOnly = o.UseGitTagOnly || c.Filename == ""
+		// each component would overwrite the same VERSION and env files
+		co.NoVersionFile = true
+		co.EnvFile = ""
+		// the channel has already been worked out from the branch
+		co.PrereleaseFromBranch = false
+		err = co.Run()
+		if err != nil {
+			return fmt.Errorf("failed to version the component %s: %v", c.Path, err)
+		}
+		log.Infof("component %s version %s\n", util.ColorInfo(c.Path), util.ColorInfo(co.NewVersion))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadVersionsConfig(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-versions-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	filename := filepath.Join(f, ".jx-versions.yaml")
+	err = ioutil.WriteFile(filename, []byte("components:\n- path: services/api\n  tagPrefix: api-v\n  filename: Makefile\n- path: web\n  tagPrefix: web-v\n"), 0644)
+	assert.NoError(t, err)
+
+	config, err := loadVersionsConfig(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []VersionComponent{
+		{Path: "services/api", TagPrefix: "api-v", Filename: "Makefile"},
+		{Path: "web", TagPrefix: "web-v"},
+	}, config.Components)
+
+	err = ioutil.WriteFile(filename, []byte("components:\n- path: api\n  tagPrefix: v\n- path: web\n  tagPrefix: v\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = loadVersionsConfig(filename)
+	assert.Error(t, err, "components must not share a tag prefix")
+}
+
+func TestRunComponents(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-run-components")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	remote := filepath.Join(f, "remote.git")
+	repo := filepath.Join(f, "repo")
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, "api"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, "web"), 0755))
+	assert.NoError(t, gits.GitCmd(f, "init", "--bare", remote))
+	assert.NoError(t, gits.GitInit(repo))
+	assert.NoError(t, gits.GitCmd(repo, "remote", "add", "origin", remote))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(repo, "api", "Makefile"), []byte("VERSION := 1.0.0\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(repo, "web", "package.json"), []byte("{\n  \"name\": \"web\",\n  \"version\": \"2.0.0\"\n}\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(repo, ".jx-versions.yaml"), []byte("components:\n- path: api\n  tagPrefix: api-v\n  filename: Makefile\n- path: web\n  tagPrefix: web-v\n  filename: package.json\n"), 0644))
+	assert.NoError(t, gits.GitCmd(repo, "add", "."))
+	assert.NoError(t, gits.GitCommitDir(repo, "first"))
+	assert.NoError(t, gits.GitCmd(repo, "tag", "api-v1.0.0"))
+	assert.NoError(t, gits.GitCmd(repo, "tag", "web-v2.0.3"))
+	assert.NoError(t, gits.GitCmd(repo, "push", "origin", "--tags"))
+	assert.NoError(t, gits.GitCmd(repo, "commit", "--allow-empty", "-m", "second"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(repo))
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		Config: ".jx-versions.yaml",
+	}
+	o.Out = tests.Output()
+	err = o.Run()
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(repo, "api", "Makefile"))
+	assert.NoError(t, err)
+	assert.Equal(t, "VERSION := 1.0.1\n", string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(repo, "web", "package.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"version": "2.0.4"`)
+
+	assert.NoError(t, gits.GitCmd(repo, "checkout", "-b", "feature/Login"))
+	o = StepNextVersionOptions{
+		Config:               ".jx-versions.yaml",
+		PrereleaseFromBranch: true,
+	}
+	o.Out = tests.Output()
+	err = o.Run()
+	assert.NoError(t, err)
+
+	b, err = ioutil.ReadFile(filepath.Join(repo, "api", "Makefile"))
+	assert.NoError(t, err)
+	assert.Equal(t, "VERSION := 1.0.1-feature-login.1\n", string(b), "each component should be released to the channel of the branch")
+}
+
+func TestRunComponentsTagOnly(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-run-components-tag-only")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(f, "api"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(f, "docs"), 0755))
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(f, "api", "Makefile"), []byte("VERSION := 1.0.0\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(f, "docs", "index.md"), []byte("# docs\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(f, ".jx-versions.yaml"), []byte("components:\n- path: api\n  tagPrefix: api-v\n  filename: Makefile\n- path: docs\n  tagPrefix: docs-v\n"), 0644))
+	assert.NoError(t, gits.GitCmd(f, "add", "."))
+	assert.NoError(t, gits.GitCommitDir(f, "first"))
+	assert.NoError(t, gits.GitCmd(f, "tag", "api-v1.0.0"))
+	assert.NoError(t, gits.GitCmd(f, "tag", "docs-v0.3.0"))
+	assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "second"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(f))
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		Config: ".jx-versions.yaml",
+		Tag:    true,
+		NoPush: true,
+	}
+	o.Out = tests.Output()
+	err = o.Run()
+	assert.NoError(t, err, "a component without a filename should be versioned by its tags")
+
+	b, err := ioutil.ReadFile(filepath.Join(f, "api", "Makefile"))
+	assert.NoError(t, err)
+	assert.Equal(t, "VERSION := 1.0.1\n", string(b))
+
+	for _, tag := range []string{"api-v1.0.1", "docs-v0.3.1"} {
+		tagged, err := o.tagExists(tag)
+		assert.NoError(t, err)
+		assert.True(t, tagged, "the tag %s should be created", tag)
+	}
+}
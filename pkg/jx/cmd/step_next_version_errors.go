@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	outputJSON = "json"
+
+	errCodeInvalidFlag     = "invalid_flag"
+	errCodeNoCommits       = "no_commits"
+	errCodeNoTags          = "no_tags"
+	errCodeFetchFailed     = "fetch_failed"
+	errCodeNoFile          = "no_file"
+	errCodeMissingFile     = "missing_file"
+	errCodeUnsupportedFile = "unsupported_file"
+	errCodeNoVersion       = "no_version"
+	errCodeParse           = "parse_error"
+	errCodeValidation      = "validation_failed"
+	errCodeDirty           = "dirty_tree"
+	errCodeTagExists       = "tag_exists"
+	errCodeHeadTagged      = "head_tagged"
+	errCodeHookFailed      = "hook_failed"
+	errCodeUnknown         = "unknown"
+)
+
+// VersionError is an error of the next version step with a stable code so automation doesn't have to match messages
+type VersionError struct {
+	Code string
+	Err  error
+}
+
+func (e *VersionError) Error() string {
+	return e.Err.Error()
+}
+
+// versionErrorf formats an error with the code of its failure class
+func versionErrorf(code string, format string, a ...interface{}) error {
+	return &VersionError{
+		Code: code,
+		Err:  fmt.Errorf(format, a...),
+	}
+}
+
+// errorCode returns the stable code of the error
+func errorCode(err error) string {
+	if e, ok := err.(*VersionError); ok {
+		return e.Code
+	}
+	if os.IsNotExist(err) {
+		return errCodeMissingFile
+	}
+	return errCodeUnknown
+}
+
+// JSONError is the error printed with the flag output json
+type JSONError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// printJSONError prints the error as a JSON object to the output
+func (o *StepNextVersionOptions) printJSONError(err error) {
+	b, merr := json.Marshal(JSONError{
+		Error: err.Error(),
+		Code:  errorCode(err),
+	})
+	if merr != nil {
+		return
+	}
+	fmt.Fprintln(o.Out, string(b))
+}
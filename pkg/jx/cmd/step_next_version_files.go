@@ -0,0 +1,2483 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	version "github.com/hashicorp/go-version"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/magiconair/properties"
+	"gopkg.in/yaml.v2"
+)
+
+// VersionFile reads and writes the version of a project in a particular file format
+type VersionFile interface {
+	// Read returns the version in the file contents or an empty string if only git tags should be used
+	Read(b []byte) (string, error)
+
+	// Write returns the file contents updated with the new version
+	Write(b []byte, newVersion string) ([]byte, error)
+}
+
+// VersionFileFactory creates a VersionFile configured from the command line options
+type VersionFileFactory func(o *StepNextVersionOptions) VersionFile
+
+var (
+	versionFileFactories = map[string]VersionFileFactory{}
+
+	// errNoVersion is returned by a VersionFile when the file does not contain a version
+	errNoVersion = errors.New("no version found")
+
+	// errReadOnlyVersionFile is returned by a VersionFile which does not support updating the version
+	errReadOnlyVersionFile = errors.New("updating the version is not supported")
+)
+
+// RegisterVersionFile registers the factory of the VersionFile for a file name such as package.json or a file
+// extension such as .gemspec
+func RegisterVersionFile(name string, factory VersionFileFactory) {
+	versionFileFactories[name] = factory
+}
+
+// supportedVersionFiles returns the sorted file names and extensions which have a registered VersionFile
+func supportedVersionFiles() []string {
+	names := []string{}
+	for name := range versionFileFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// versionFileFor returns the VersionFile registered for the file name and its parent directory, such as
+// meta/main.yml, then for the file name or else its extension, or nil if there is none
+func (o *StepNextVersionOptions) versionFileFor(filename string) VersionFile {
+	if o.JSONPath != "" {
+		return &jsonPathVersionFile{
+			path: strings.Split(strings.TrimPrefix(o.JSONPath, "$."), "."),
+		}
+	}
+	if o.YamlPath != "" {
+		return &yamlPathVersionFile{
+			path: strings.Split(o.YamlPath, "."),
+		}
+	}
+	if o.Regex != "" || o.WriteRegex != "" {
+		return &regexVersionFile{
+			regex:         o.Regex,
+			group:         o.Group,
+			writeRegex:    o.WriteRegex,
+			writeTemplate: o.WriteTemplate,
+		}
+	}
+	if o.Commitizen {
+		return &tomlVersionFile{
+			table: commitizenTable,
+		}
+	}
+	name := filepath.Base(filename)
+	factory := versionFileFactories[filepath.Base(filepath.Dir(filename))+"/"+name]
+	if factory == nil {
+		factory = versionFileFactories[name]
+	}
+	if factory == nil {
+		factory = versionFileFactories[filepath.Ext(name)]
+	}
+	if factory == nil {
+		return nil
+	}
+	return factory(o)
+}
+
+func init() {
+	RegisterVersionFile(chartyaml, func(o *StepNextVersionOptions) VersionFile {
+		field := o.ChartField
+		if field == "" {
+			field = chartVersion
+		}
+		return &chartVersionFile{
+			field:          field,
+			syncAppVersion: o.ChartSyncAppVersion,
+		}
+	})
+	RegisterVersionFile(packagejson, func(o *StepNextVersionOptions) VersionFile {
+		return &packageJSONVersionFile{}
+	})
+	RegisterVersionFile(pomxml, func(o *StepNextVersionOptions) VersionFile {
+		return &pomVersionFile{}
+	})
+	keyAssignment := func(o *StepNextVersionOptions) VersionFile {
+		keys := o.VersionKeys
+		if len(keys) == 0 {
+			keys = []string{"VERSION"}
+		}
+		return &keyAssignmentVersionFile{
+			keys: keys,
+		}
+	}
+	RegisterVersionFile(makefile, keyAssignment)
+	RegisterVersionFile(dockerfile, keyAssignment)
+	RegisterVersionFile(projectclj, func(o *StepNextVersionOptions) VersionFile {
+		return &projectCljVersionFile{}
+	})
+	RegisterVersionFile(mesonbuild, func(o *StepNextVersionOptions) VersionFile {
+		return &mesonVersionFile{}
+	})
+	RegisterVersionFile(configureac, func(o *StepNextVersionOptions) VersionFile {
+		return &configureAcVersionFile{}
+	})
+	RegisterVersionFile(swiftpkg, func(o *StepNextVersionOptions) VersionFile {
+		return &swiftVersionFile{}
+	})
+	RegisterVersionFile(snapcraftyaml, func(o *StepNextVersionOptions) VersionFile {
+		return &snapcraftVersionFile{}
+	})
+	RegisterVersionFile(elmjson, func(o *StepNextVersionOptions) VersionFile {
+		return &elmVersionFile{}
+	})
+	deno := func(o *StepNextVersionOptions) VersionFile {
+		return &denoVersionFile{}
+	}
+	RegisterVersionFile(denojson, deno)
+	RegisterVersionFile(denojsonc, deno)
+	RegisterVersionFile(bumpversion, func(o *StepNextVersionOptions) VersionFile {
+		return &bumpversionVersionFile{}
+	})
+	rubySpec := func(o *StepNextVersionOptions) VersionFile {
+		return &rubySpecVersionFile{
+			requireLiteral: o.RequireLiteral,
+		}
+	}
+	RegisterVersionFile(gemspecExt, rubySpec)
+	RegisterVersionFile(podspecExt, rubySpec)
+	rubyConstant := func(o *StepNextVersionOptions) VersionFile {
+		return &rubyConstantVersionFile{}
+	}
+	RegisterVersionFile(rbExt, rubyConstant)
+	RegisterVersionFile(vagrantfile, rubyConstant)
+	RegisterVersionFile(wxsExt, func(o *StepNextVersionOptions) VersionFile {
+		return &wixVersionFile{}
+	})
+	RegisterVersionFile(infoplist, func(o *StepNextVersionOptions) VersionFile {
+		key := o.PlistKey
+		if key == "" {
+			key = plistShortVersion
+		}
+		return &plistVersionFile{
+			key: key,
+		}
+	})
+	RegisterVersionFile(cargotoml, func(o *StepNextVersionOptions) VersionFile {
+		return &cargoVersionFile{
+			dir: filepath.Dir(filepath.Join(o.Dir, o.Filename)),
+		}
+	})
+	kustomize := func(o *StepNextVersionOptions) VersionFile {
+		return &kustomizeVersionFile{
+			image: o.KustomizeImage,
+		}
+	}
+	RegisterVersionFile(kustomizationyaml, kustomize)
+	RegisterVersionFile(kustomizationyml, kustomize)
+	compose := func(o *StepNextVersionOptions) VersionFile {
+		return &composeVersionFile{
+			service: o.ComposeService,
+		}
+	}
+	for _, name := range []string{dockercomposeyml, dockercomposeyaml, composeyml, composeyaml} {
+		RegisterVersionFile(name, compose)
+	}
+	RegisterVersionFile(ansiblemetayml, func(o *StepNextVersionOptions) VersionFile {
+		return &ansibleRoleVersionFile{}
+	})
+	RegisterVersionFile(ansiblemetayaml, func(o *StepNextVersionOptions) VersionFile {
+		return &ansibleRoleVersionFile{}
+	})
+	RegisterVersionFile(nixExt, func(o *StepNextVersionOptions) VersionFile {
+		return &nixVersionFile{}
+	})
+	RegisterVersionFile(projecttoml, func(o *StepNextVersionOptions) VersionFile {
+		return &tomlVersionFile{}
+	})
+	commitizen := func(o *StepNextVersionOptions) VersionFile {
+		return &tomlVersionFile{
+			table: commitizenTable,
+		}
+	}
+	RegisterVersionFile(dotcztoml, commitizen)
+	RegisterVersionFile(cztoml, commitizen)
+	RegisterVersionFile(libsversionstoml, func(o *StepNextVersionOptions) VersionFile {
+		return &tomlVersionFile{
+			table: "versions",
+			key:   o.CatalogKey,
+		}
+	})
+	RegisterVersionFile(settingsgradle, func(o *StepNextVersionOptions) VersionFile {
+		return &gradleSettingsVersionFile{}
+	})
+	RegisterVersionFile(settingsgradlekts, func(o *StepNextVersionOptions) VersionFile {
+		return &gradleSettingsVersionFile{}
+	})
+	RegisterVersionFile(pmExt, func(o *StepNextVersionOptions) VersionFile {
+		return &perlVersionFile{}
+	})
+	RegisterVersionFile(cabalExt, func(o *StepNextVersionOptions) VersionFile {
+		return &cabalVersionFile{}
+	})
+	RegisterVersionFile(phpExt, func(o *StepNextVersionOptions) VersionFile {
+		return &phpHeaderVersionFile{}
+	})
+	RegisterVersionFile(pubspecyaml, func(o *StepNextVersionOptions) VersionFile {
+		return &pubspecVersionFile{
+			incrementBuildNumber: o.IncrementBuildNumber,
+		}
+	})
+	// the version is in the pubspec.yaml of the package of the Melos workspace
+	RegisterVersionFile(melosyaml, func(o *StepNextVersionOptions) VersionFile {
+		return &pubspecVersionFile{
+			incrementBuildNumber: o.IncrementBuildNumber,
+		}
+	})
+	RegisterVersionFile(releasepleasejson, func(o *StepNextVersionOptions) VersionFile {
+		path := o.ManifestPath
+		if path == "" {
+			path = "."
+		}
+		return &releasePleaseVersionFile{
+			path: path,
+		}
+	})
+	RegisterVersionFile(amperyaml, func(o *StepNextVersionOptions) VersionFile {
+		// the version of an Amper module is the version it is published with
+		return &yamlPathVersionFile{
+			path: []string{"settings", "publishing", "version"},
+		}
+	})
+	RegisterVersionFile(gemfilelock, func(o *StepNextVersionOptions) VersionFile {
+		return &gemfileLockVersionFile{
+			gem: o.LockGem,
+		}
+	})
+	RegisterVersionFile(dprojExt, func(o *StepNextVersionOptions) VersionFile {
+		return &dprojVersionFile{}
+	})
+	RegisterVersionFile(directorybuildprops, func(o *StepNextVersionOptions) VersionFile {
+		return &msbuildPropsVersionFile{}
+	})
+	RegisterVersionFile(vcpkgjson, func(o *StepNextVersionOptions) VersionFile {
+		return &vcpkgVersionFile{}
+	})
+	RegisterVersionFile(conanfilepy, func(o *StepNextVersionOptions) VersionFile {
+		return &conanVersionFile{}
+	})
+	RegisterVersionFile(cmakelists, func(o *StepNextVersionOptions) VersionFile {
+		return &cmakeVersionFile{}
+	})
+	RegisterVersionFile(modulebazel, func(o *StepNextVersionOptions) VersionFile {
+		return &bazelModuleVersionFile{}
+	})
+	for _, name := range []string{openapiyaml, openapiyml, openapijson, swaggeryaml, swaggeryml, swaggerjson} {
+		isJSON := strings.HasSuffix(name, ".json")
+		RegisterVersionFile(name, func(o *StepNextVersionOptions) VersionFile {
+			return &openAPIVersionFile{
+				json: isJSON,
+			}
+		})
+	}
+	RegisterVersionFile(dotversion, func(o *StepNextVersionOptions) VersionFile {
+		return &plainVersionFile{}
+	})
+	RegisterVersionFile(pkgbuild, func(o *StepNextVersionOptions) VersionFile {
+		return &pkgbuildVersionFile{}
+	})
+	RegisterVersionFile(debianChangelog, func(o *StepNextVersionOptions) VersionFile {
+		return &debianChangelogVersionFile{
+			now: time.Now,
+		}
+	})
+	RegisterVersionFile(specExt, func(o *StepNextVersionOptions) VersionFile {
+		return &rpmSpecVersionFile{
+			resetRelease: o.ResetRelease,
+		}
+	})
+	RegisterVersionFile(propertiesExt, func(o *StepNextVersionOptions) VersionFile {
+		key := o.PropertyKey
+		if key == "" {
+			key = "version"
+		}
+		return &propertiesVersionFile{
+			key: key,
+		}
+	})
+	RegisterVersionFile(tfExt, func(o *StepNextVersionOptions) VersionFile {
+		return &hclVersionFile{
+			attribute: o.HclAttribute,
+		}
+	})
+}
+
+// readOnlyVersionFile can be embedded by a VersionFile which only supports reading the version
+type readOnlyVersionFile struct{}
+
+func (readOnlyVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return nil, errReadOnlyVersionFile
+}
+
+type ChartYaml struct {
+	Version    string `yaml:"version"`
+	AppVersion string `yaml:"appVersion"`
+}
+
+// chartVersionFile handles the version or appVersion field of a helm Chart.yaml
+type chartVersionFile struct {
+	field          string
+	syncAppVersion bool
+}
+
+func (f *chartVersionFile) Read(b []byte) (string, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var chart ChartYaml
+		err := decoder.Decode(&chart)
+		if err == io.EOF {
+			return "", errNoVersion
+		}
+		if err != nil {
+			return "", err
+		}
+		v := chart.Version
+		if f.field == chartAppVersion {
+			v = chart.AppVersion
+		}
+		if v != "" {
+			return v, nil
+		}
+	}
+}
+
+// Write replaces the first top level value of the field, keeping any quotes or anchor around it so that nested
+// dependency versions are left alone. When syncing the appVersion both fields are replaced, adding an appVersion after
+// the version if the chart doesn't have one
+func (f *chartVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	if !f.syncAppVersion {
+		return writeChartField(b, f.field, newVersion)
+	}
+	output, err := writeChartField(b, chartVersion, newVersion)
+	if err != nil {
+		return nil, err
+	}
+	if chartFieldRegex(chartAppVersion).Match(output) {
+		return writeChartField(output, chartAppVersion, newVersion)
+	}
+	loc := chartFieldRegex(chartVersion).FindIndex(output)
+	end := len(output)
+	if i := bytes.IndexByte(output[loc[1]:], '\n'); i >= 0 {
+		end = loc[1] + i + 1
+	}
+	line := []byte(chartAppVersion + ": " + newVersion + "\n")
+	if end == len(output) && !bytes.HasSuffix(output, []byte("\n")) {
+		line = append([]byte("\n"), line...)
+	}
+	result := append([]byte{}, output[:end]...)
+	result = append(result, line...)
+	return append(result, output[end:]...), nil
+}
+
+// chartFieldRegex matches the top level value of a field of a Chart.yaml
+func chartFieldRegex(field string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^(` + regexp.QuoteMeta(field) + `:[ \t]*(?:&\S+[ \t]+)?["']?)([^"'\s#*][^"'\s#]*)`)
+}
+
+// writeChartField replaces the first top level value of the field of a Chart.yaml
+func writeChartField(b []byte, field string, newVersion string) ([]byte, error) {
+	loc := chartFieldRegex(field).FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, fmt.Errorf("no top level %s found, aliases are not supported", field)
+	}
+	output := append([]byte{}, b[:loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[loc[5]:]...), nil
+}
+
+type packageJSONVersionFile struct{}
+
+func (f *packageJSONVersionFile) Read(b []byte) (string, error) {
+	var jsPackage PackageJSON
+	err := json.Unmarshal(b, &jsPackage)
+	if err != nil {
+		return "", err
+	}
+	if jsPackage.Version == "" {
+		return "", errNoVersion
+	}
+	return jsPackage.Version, nil
+}
+
+func (f *packageJSONVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setTopLevelJSONStringField(b, "version", newVersion)
+}
+
+// pomVersionFile handles the version of a pom.xml, which can only be updated when it is a CI friendly version such as
+// ${revision} as the revision property is then the single place the version is defined
+type pomVersionFile struct{}
+
+func (f *pomVersionFile) Read(b []byte) (string, error) {
+	var project Project
+	xml.Unmarshal(b, &project)
+	if project.Version == "" {
+		return "", errNoVersion
+	}
+	if !isPomRevision(project.Version) {
+		return project.Version, nil
+	}
+	properties := project.Properties
+	v := strings.NewReplacer("${revision}", properties.Revision, "${sha1}", properties.Sha1, "${changelist}", properties.Changelist).Replace(project.Version)
+	if properties.Revision == "" || strings.Contains(v, "${") {
+		return "", fmt.Errorf("cannot resolve the version %s from the properties", project.Version)
+	}
+	return v, nil
+}
+
+func (f *pomVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	var project Project
+	xml.Unmarshal(b, &project)
+	if !isPomRevision(project.Version) {
+		return nil, errReadOnlyVersionFile
+	}
+	_, start, end, err := findXMLText(b, "project", "properties", "revision")
+	if err != nil {
+		return nil, err
+	}
+	return replaceXMLText(b, start, end, newVersion)
+}
+
+// isPomRevision returns true for a CI friendly version of a pom.xml such as ${revision} or ${revision}${changelist}
+func isPomRevision(v string) bool {
+	return strings.Contains(v, "${revision}")
+}
+
+// keyAssignmentVersionFile handles files such as a Makefile or Dockerfile where the version is assigned to one or
+// more keys, possibly more than once. The version is read from the first assignment found and every assignment of
+// every key is updated when writing. Assignments of another variable such as $(VERSION) are left untouched
+type keyAssignmentVersionFile struct {
+	keys []string
+}
+
+// keyAssignmentRegex matches `KEY := value`, `KEY ?= value`, `KEY=value`, `export KEY = value`, `ARG KEY=value`,
+// `ENV KEY value` and `LABEL key="value"` style assignments of the key. A value can't start with an operator so
+// `KEY := $(shell cat VERSION)` isn't read as `KEY` followed by the value `:=`
+func keyAssignmentRegex(key string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^([ \t]*(?:[^\s:=]+:[ \t]*)?(?:export[ \t]+|ARG[ \t]+|ENV[ \t]+|LABEL[ \t]+)?` +
+		regexp.QuoteMeta(key) + `(?:[ \t]*[:?]?=[ \t]*|[ \t]+)["']?)([^"'\s#$:?=][^"'\s#]*)`)
+}
+
+func (f *keyAssignmentVersionFile) Read(b []byte) (string, error) {
+	first := -1
+	v := ""
+	for _, key := range f.keys {
+		loc := keyAssignmentRegex(key).FindSubmatchIndex(b)
+		if loc != nil && (first < 0 || loc[0] < first) {
+			first = loc[0]
+			v = string(b[loc[4]:loc[5]])
+		}
+	}
+	if v == "" {
+		return "", errNoVersion
+	}
+	return v, nil
+}
+
+func (f *keyAssignmentVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	output := b
+	found := false
+	for _, key := range f.keys {
+		regex := keyAssignmentRegex(key)
+		if regex.Match(output) {
+			found = true
+			output = regex.ReplaceAll(output, []byte("${1}"+newVersion))
+		}
+	}
+	if !found {
+		return nil, errNoVersion
+	}
+	return output, nil
+}
+
+type projectCljVersionFile struct {
+	readOnlyVersionFile
+}
+
+func (f *projectCljVersionFile) Read(b []byte) (string, error) {
+	regex := regexp.MustCompile(`\(defproject\s+\S+\s+"([^"]*)"`)
+	matched := regex.FindStringSubmatch(string(b))
+	if len(matched) > 1 && matched[1] != "" {
+		return matched[1], nil
+	}
+	return "", errNoVersion
+}
+
+type mesonVersionFile struct {
+	readOnlyVersionFile
+}
+
+func (f *mesonVersionFile) Read(b []byte) (string, error) {
+	args := findCallArguments(string(b), "project")
+	regex := regexp.MustCompile(`\bversion\s*:\s*'([^']*)'`)
+	matched := regex.FindStringSubmatch(args)
+	if len(matched) > 1 && matched[1] != "" {
+		return matched[1], nil
+	}
+	return "", errNoVersion
+}
+
+var cmakeVersionRegex = regexp.MustCompile(`(?i)(\bVERSION\s+"?)([^\s")]+)`)
+
+// cmakeVersionFile handles the VERSION argument of the project() command of a CMakeLists.txt, where commands and
+// keywords are case insensitive and the arguments may span several lines
+type cmakeVersionFile struct{}
+
+func (f *cmakeVersionFile) Read(b []byte) (string, error) {
+	start, end := findCallArgumentsIndex(string(b), "(?i:project)")
+	if start < 0 {
+		return "", errNoVersion
+	}
+	matched := cmakeVersionRegex.FindSubmatch(b[start:end])
+	if len(matched) < 3 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *cmakeVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	start, end := findCallArgumentsIndex(string(b), "(?i:project)")
+	if start < 0 {
+		return nil, errNoVersion
+	}
+	loc := cmakeVersionRegex.FindSubmatchIndex(b[start:end])
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	output := append([]byte{}, b[:start+loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[start+loc[5]:]...), nil
+}
+
+var bazelVersionRegex = regexp.MustCompile(`(\bversion\s*=\s*["'])([^"']*)`)
+
+// bazelModuleVersionFile handles the version argument of the module() call of a Bzlmod MODULE.bazel, ignoring the
+// versions of the bazel_dep() dependencies
+type bazelModuleVersionFile struct{}
+
+func (f *bazelModuleVersionFile) Read(b []byte) (string, error) {
+	start, end := findCallArgumentsIndex(string(b), "module")
+	if start < 0 {
+		return "", errNoVersion
+	}
+	matched := bazelVersionRegex.FindSubmatch(b[start:end])
+	if len(matched) < 3 || len(matched[2]) == 0 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *bazelModuleVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	start, end := findCallArgumentsIndex(string(b), "module")
+	if start < 0 {
+		return nil, errNoVersion
+	}
+	loc := bazelVersionRegex.FindSubmatchIndex(b[start:end])
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	output := append([]byte{}, b[:start+loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[start+loc[5]:]...), nil
+}
+
+type configureAcVersionFile struct {
+	readOnlyVersionFile
+}
+
+func (f *configureAcVersionFile) Read(b []byte) (string, error) {
+	args := findM4Arguments(string(b), "AC_INIT")
+	if len(args) > 1 && args[1] != "" {
+		return args[1], nil
+	}
+	return "", errNoVersion
+}
+
+// swiftVersionFile uses git tags only as swift packages are versioned by their tags
+type swiftVersionFile struct{}
+
+func (f *swiftVersionFile) Read(b []byte) (string, error) {
+	// a hardcoded version would silently disagree with the git tags
+	regex := regexp.MustCompile(`(?m)^\s*(let|var)\s+version\s*=\s*"([^"]*)"`)
+	matched := regex.FindStringSubmatch(string(b))
+	if len(matched) > 2 {
+		return "", fmt.Errorf("%s declares a hardcoded version %s which conflicts with git tag based versioning, please remove it", swiftpkg, matched[2])
+	}
+	return "", nil
+}
+
+func (f *swiftVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	// swift packages are versioned by the git tag alone so there is nothing to update
+	return b, nil
+}
+
+type SnapcraftYaml struct {
+	Version string `yaml:"version"`
+}
+
+type snapcraftVersionFile struct{}
+
+func (f *snapcraftVersionFile) Read(b []byte) (string, error) {
+	// only the top level version is the snap version, parts may declare their own
+	var snap SnapcraftYaml
+	err := yaml.Unmarshal(b, &snap)
+	if err != nil {
+		return "", err
+	}
+	if snap.Version == "" {
+		return "", errNoVersion
+	}
+	return snap.Version, nil
+}
+
+func (f *snapcraftVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setYamlPathString(b, []string{"version"}, newVersion)
+}
+
+// kustomizeVersionFile handles the newTag of an image in the images of a kustomization.yaml
+type kustomizeVersionFile struct {
+	image string
+}
+
+func (f *kustomizeVersionFile) Read(b []byte) (string, error) {
+	doc, image, err := f.findImage(b)
+	if err != nil {
+		return "", err
+	}
+	for _, item := range doc[image].Value.([]interface{}) {
+		entry, ok := item.(yaml.MapSlice)
+		if !ok || yamlMapValue(entry, "name") != f.image {
+			continue
+		}
+		if tag, ok := yamlMapValue(entry, "newTag").(string); ok && tag != "" {
+			return tag, nil
+		}
+	}
+	return "", errNoVersion
+}
+
+// Write edits the newTag line of the entry of the image in place, adding one if there isn't one, so that the comments
+// and formatting of the rest of the kustomization are kept
+func (f *kustomizeVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	_, _, err := f.findImage(b)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(b), "\n")
+	for _, entry := range kustomizeImageEntries(lines) {
+		start, end := entry[0], entry[1]
+		// the entry is a mapping once its sequence indicator is replaced by spaces
+		first := lines[start]
+		dash := strings.Index(first, "-")
+		block := append([]string{first[:dash] + " " + first[dash+1:]}, lines[start+1:end]...)
+		name, err := getYamlPathString([]byte(strings.Join(block, "\n")), []string{"name"})
+		if err != nil || name != f.image {
+			continue
+		}
+		output, err := setYamlPathString([]byte(strings.Join(block, "\n")), []string{"newTag"}, newVersion)
+		if err == nil {
+			updated := strings.Split(string(output), "\n")
+			updated[0] = first[:dash] + "-" + updated[0][dash+1:]
+			lines = append(lines[:start], append(updated, lines[end:]...)...)
+			return []byte(strings.Join(lines, "\n")), nil
+		}
+		// add the newTag after the last line of the entry
+		last := end - 1
+		for last > start && strings.TrimSpace(lines[last]) == "" {
+			last--
+		}
+		indent := strings.Repeat(" ", dash+1+len(first[dash+1:])-len(strings.TrimLeft(first[dash+1:], " ")))
+		newTag := indent + "newTag: " + newVersion
+		lines = append(lines[:last+1], append([]string{newTag}, lines[last+1:]...)...)
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+	return nil, fmt.Errorf("no image %s found in the kustomization images", f.image)
+}
+
+var kustomizeImagesRegex = regexp.MustCompile(`^images:\s*(#.*)?$`)
+
+// kustomizeImageEntries returns the start and end line indexes of each entry of the top level images sequence
+func kustomizeImageEntries(lines []string) [][2]int {
+	entries := [][2]int{}
+	inImages := false
+	seqIndent := -1
+	start := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if !inImages {
+			inImages = indent == 0 && kustomizeImagesRegex.MatchString(line)
+			continue
+		}
+		isItem := strings.HasPrefix(trimmed, "- ") || trimmed == "-"
+		if seqIndent < 0 && isItem {
+			seqIndent = indent
+		}
+		if isItem && indent == seqIndent {
+			if start >= 0 {
+				entries = append(entries, [2]int{start, i})
+			}
+			start = i
+			continue
+		}
+		if indent <= seqIndent || (seqIndent < 0 && indent == 0) {
+			// the next top level key
+			break
+		}
+	}
+	if start >= 0 {
+		end := len(lines)
+		for i := start + 1; i < len(lines); i++ {
+			trimmed := strings.TrimSpace(lines[i])
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if len(lines[i])-len(strings.TrimLeft(lines[i], " ")) <= seqIndent {
+				end = i
+				break
+			}
+		}
+		entries = append(entries, [2]int{start, end})
+	}
+	return entries
+}
+
+// findImage returns the parsed kustomization and the index of its images, checking the image is in them
+func (f *kustomizeVersionFile) findImage(b []byte) (yaml.MapSlice, int, error) {
+	if f.image == "" {
+		return nil, 0, fmt.Errorf("please specify the image whose newTag is the version with the flag kustomize-image")
+	}
+	doc := yaml.MapSlice{}
+	err := yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, item := range doc {
+		if item.Key == "images" {
+			images, ok := item.Value.([]interface{})
+			if !ok {
+				return nil, 0, fmt.Errorf("the kustomization images is not a list")
+			}
+			for _, image := range images {
+				if entry, ok := image.(yaml.MapSlice); ok && yamlMapValue(entry, "name") == f.image {
+					return doc, i, nil
+				}
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("no image %s found in the kustomization images", f.image)
+}
+
+// yamlMapValue returns the value of the key in the YAML map or nil if there is none
+func yamlMapValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// composeVersionFile handles the tag of the image of a service in a docker-compose.yml
+type composeVersionFile struct {
+	service string
+}
+
+func (f *composeVersionFile) Read(b []byte) (string, error) {
+	_, image, err := f.findImage(b)
+	if err != nil {
+		return "", err
+	}
+	_, tag := splitImageTag(image)
+	if tag == "" {
+		return "", errNoVersion
+	}
+	return tag, nil
+}
+
+func (f *composeVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	path, image, err := f.findImage(b)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := splitImageTag(image)
+	return setYamlPathString(b, path, name+":"+newVersion)
+}
+
+// findImage returns the path to the image of the service and its current value
+func (f *composeVersionFile) findImage(b []byte) ([]string, string, error) {
+	if f.service == "" {
+		return nil, "", fmt.Errorf("please specify the service whose image tag is the version with the flag compose-service")
+	}
+	doc := yaml.MapSlice{}
+	err := yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, "", err
+	}
+	path := []string{f.service, "image"}
+	services, ok := yamlMapValue(doc, "services").(yaml.MapSlice)
+	if ok {
+		path = append([]string{"services"}, path...)
+	} else {
+		// version 1 compose files have the services at the top level
+		services = doc
+	}
+	service, ok := yamlMapValue(services, f.service).(yaml.MapSlice)
+	if !ok {
+		return nil, "", fmt.Errorf("no service %s found in the compose file", f.service)
+	}
+	image, ok := yamlMapValue(service, "image").(string)
+	if !ok || image == "" {
+		return nil, "", fmt.Errorf("the service %s does not have an image", f.service)
+	}
+	return path, image, nil
+}
+
+// splitImageTag splits a docker image into its name and tag, ignoring any digest and registry port
+func splitImageTag(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return image, ""
+	}
+	return image[:i], image[i+1:]
+}
+
+// vcpkgVersionFields are the alternative fields of a vcpkg.json version depending on its scheme
+var vcpkgVersionFields = []string{"version", "version-semver", "version-date", "version-string"}
+
+// vcpkgVersionFile handles the version of a vcpkg.json manifest in whichever version field it uses
+type vcpkgVersionFile struct{}
+
+func (f *vcpkgVersionFile) Read(b []byte) (string, error) {
+	manifest := map[string]interface{}{}
+	err := json.Unmarshal(b, &manifest)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range vcpkgVersionFields {
+		if v, ok := manifest[field].(string); ok && v != "" {
+			return v, nil
+		}
+	}
+	return "", errNoVersion
+}
+
+func (f *vcpkgVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	manifest := map[string]interface{}{}
+	err := json.Unmarshal(b, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range vcpkgVersionFields {
+		if _, ok := manifest[field].(string); ok {
+			return setTopLevelJSONStringField(b, field, newVersion)
+		}
+	}
+	return nil, errNoVersion
+}
+
+var conanVersionRegex = regexp.MustCompile(`(?m)^([ \t]*version[ \t]*=[ \t]*["'])([^"'\n]*)(["'])`)
+
+// conanVersionFile handles the version attribute of the ConanFile class in a conanfile.py
+type conanVersionFile struct{}
+
+func (f *conanVersionFile) Read(b []byte) (string, error) {
+	matched := conanVersionRegex.FindSubmatch(b)
+	if len(matched) < 3 || len(matched[2]) == 0 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *conanVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	loc := conanVersionRegex.FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	output := append([]byte{}, b[:loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[loc[5]:]...), nil
+}
+
+// nixVersionRegex matches a literal version attribute, skipping any which interpolate other attributes
+var nixVersionRegex = regexp.MustCompile(`(?m)^([ \t]*version[ \t]*=[ \t]*")([^"$\n]*)("[ \t]*;)`)
+
+// nixVersionFile handles the version attribute of a derivation in a .nix file such as default.nix or flake.nix
+type nixVersionFile struct{}
+
+func (f *nixVersionFile) Read(b []byte) (string, error) {
+	matched := nixVersionRegex.FindSubmatch(b)
+	if len(matched) < 3 || len(matched[2]) == 0 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *nixVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	loc := nixVersionRegex.FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	output := append([]byte{}, b[:loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[loc[5]:]...), nil
+}
+
+var perlVersionRegex = regexp.MustCompile(`(?m)^([ \t]*(?:our[ \t]+)?\$(?:[\w:]+::)?VERSION[ \t]*=[ \t]*['"]?)(v?[0-9][0-9._]*)(['"]?[ \t]*;)`)
+
+// perlVersionFile handles the $VERSION of a Perl module which can be a decimal version such as 1.23 or a dotted
+// version such as v1.2.3
+type perlVersionFile struct{}
+
+func (f *perlVersionFile) Read(b []byte) (string, error) {
+	matched := perlVersionRegex.FindSubmatch(b)
+	if len(matched) < 3 || len(matched[2]) == 0 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *perlVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	loc := perlVersionRegex.FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	if b[loc[4]] == 'v' {
+		newVersion = "v" + newVersion
+	}
+	output := append([]byte{}, b[:loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[loc[5]:]...), nil
+}
+
+// cabalVersionRegex matches the top level version field, field names are case insensitive and indented fields belong
+// to a section such as an executable
+var cabalVersionRegex = regexp.MustCompile(`(?mi)^(version[ \t]*:[ \t]*)([^\s]+)`)
+
+// cabalVersionFile handles the version field of the package description in a Haskell .cabal file
+type cabalVersionFile struct{}
+
+func (f *cabalVersionFile) Read(b []byte) (string, error) {
+	matched := cabalVersionRegex.FindSubmatch(b)
+	if len(matched) < 3 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *cabalVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	loc := cabalVersionRegex.FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	output := append([]byte{}, b[:loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[loc[5]:]...), nil
+}
+
+// yamlPathVersionFile handles the version at a user supplied path of keys in any YAML file
+type yamlPathVersionFile struct {
+	path []string
+}
+
+func (f *yamlPathVersionFile) Read(b []byte) (string, error) {
+	return getYamlPathString(b, f.path)
+}
+
+func (f *yamlPathVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setYamlPathString(b, f.path, newVersion)
+}
+
+// jsonPathVersionFile handles the version at a user supplied path of keys in any JSON file
+type jsonPathVersionFile struct {
+	path []string
+}
+
+func (f *jsonPathVersionFile) Read(b []byte) (string, error) {
+	var value interface{}
+	err := json.Unmarshal(b, &value)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range f.path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", errNoVersion
+		}
+		value = object[key]
+	}
+	v, ok := value.(string)
+	if !ok || v == "" {
+		return "", errNoVersion
+	}
+	return v, nil
+}
+
+func (f *jsonPathVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setJSONPathString(b, f.path, newVersion)
+}
+
+// regexVersionFile handles the version in any file as a capture group of a user supplied regular expression. The
+// version can be written with a different regular expression and as a template of the new version
+type regexVersionFile struct {
+	regex         string
+	group         int
+	writeRegex    string
+	writeTemplate string
+}
+
+func (f *regexVersionFile) Read(b []byte) (string, error) {
+	pattern := f.regex
+	if pattern == "" {
+		pattern = f.writeRegex
+	}
+	loc, err := f.find(pattern, b)
+	if err != nil {
+		return "", err
+	}
+	return string(b[loc[0]:loc[1]]), nil
+}
+
+func (f *regexVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	pattern := f.writeRegex
+	if pattern == "" {
+		pattern = f.regex
+	}
+	loc, err := f.find(pattern, b)
+	if err != nil {
+		return nil, err
+	}
+	text := newVersion
+	if f.writeTemplate != "" {
+		text, err = executeVersionTemplate(f.writeTemplate, newVersion, time.Now())
+		if err != nil {
+			return nil, versionErrorf(errCodeInvalidFlag, "invalid write-template %s: %v", f.writeTemplate, err)
+		}
+	}
+	output := append([]byte{}, b[:loc[0]]...)
+	output = append(output, []byte(text)...)
+	return append(output, b[loc[1]:]...), nil
+}
+
+// find returns the start and end of the capture group of the first match of the regular expression
+func (f *regexVersionFile) find(pattern string, b []byte) ([]int, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, versionErrorf(errCodeInvalidFlag, "invalid regex %s: %v", pattern, err)
+	}
+	if f.group < 1 || f.group > regex.NumSubexp() {
+		return nil, versionErrorf(errCodeInvalidFlag, "the regex %s has no capture group %d", pattern, f.group)
+	}
+	loc := regex.FindSubmatchIndex(b)
+	if loc == nil || loc[2*f.group] < 0 || loc[2*f.group] == loc[2*f.group+1] {
+		return nil, errNoVersion
+	}
+	return loc[2*f.group : 2*f.group+2], nil
+}
+
+var (
+	phpHeaderRegex        = regexp.MustCompile(`^\s*(<\?php\s*)?/\*[\s\S]*?\*/`)
+	phpHeaderVersionRegex = regexp.MustCompile(`(?m)^[ \t*]*Version:[ \t]*(\S+)`)
+)
+
+// phpHeaderVersionFile handles the Version: line of the header comment at the top of the main PHP file of a
+// WordPress plugin, ignoring any other comments
+type phpHeaderVersionFile struct{}
+
+func (f *phpHeaderVersionFile) Read(b []byte) (string, error) {
+	loc := f.find(b)
+	if loc == nil {
+		return "", errNoVersion
+	}
+	return string(b[loc[0]:loc[1]]), nil
+}
+
+func (f *phpHeaderVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	loc := f.find(b)
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	output := append([]byte{}, b[:loc[0]]...)
+	output = append(output, []byte(newVersion)...)
+	return append(output, b[loc[1]:]...), nil
+}
+
+// find returns the start and end of the version in the leading header comment
+func (f *phpHeaderVersionFile) find(b []byte) []int {
+	header := phpHeaderRegex.Find(b)
+	if header == nil {
+		return nil
+	}
+	loc := phpHeaderVersionRegex.FindSubmatchIndex(header)
+	if loc == nil {
+		return nil
+	}
+	return loc[2:4]
+}
+
+// pubspecVersionFile handles the version of a Dart or Flutter pubspec.yaml. The build number after the + is kept
+// when writing a new version, or incremented if it must change for every build
+type pubspecVersionFile struct {
+	incrementBuildNumber bool
+}
+
+func (f *pubspecVersionFile) Read(b []byte) (string, error) {
+	v, err := getYamlPathString(b, []string{"version"})
+	if err != nil {
+		return "", errNoVersion
+	}
+	return v, nil
+}
+
+func (f *pubspecVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	current, err := getYamlPathString(b, []string{"version"})
+	if err != nil {
+		return nil, err
+	}
+	if i := strings.Index(newVersion, "+"); i >= 0 {
+		newVersion = newVersion[:i]
+	}
+	build := ""
+	if i := strings.Index(current, "+"); i >= 0 {
+		build = current[i+1:]
+	}
+	if f.incrementBuildNumber {
+		n := 0
+		if build != "" {
+			n, err = strconv.Atoi(build)
+			if err != nil {
+				return nil, fmt.Errorf("cannot increment the build number %s of version %s as it is not a number", build, current)
+			}
+		}
+		build = strconv.Itoa(n + 1)
+	}
+	if build != "" {
+		newVersion += "+" + build
+	}
+	return setYamlPathString(b, []string{"version"}, newVersion)
+}
+
+// releasePleaseVersionFile handles the version of a component in a release-please manifest, which maps the path of
+// each component to its version with . for the root of the repository
+type releasePleaseVersionFile struct {
+	path string
+}
+
+func (f *releasePleaseVersionFile) Read(b []byte) (string, error) {
+	manifest := map[string]interface{}{}
+	err := json.Unmarshal(b, &manifest)
+	if err != nil {
+		return "", err
+	}
+	v, ok := manifest[f.path].(string)
+	if !ok || v == "" {
+		return "", errNoVersion
+	}
+	return v, nil
+}
+
+func (f *releasePleaseVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setJSONPathString(b, []string{f.path}, newVersion)
+}
+
+var gemfileLockSpecRegex = regexp.MustCompile(`^    ([^\s(]+) \(([^)]+)\)\s*$`)
+
+// gemfileLockVersionFile handles the version of a gem of the repository itself, which is locked in the specs of the
+// PATH section of a Gemfile.lock
+type gemfileLockVersionFile struct {
+	gem string
+}
+
+func (f *gemfileLockVersionFile) Read(b []byte) (string, error) {
+	_, i, err := f.find(b)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(b), "\n")
+	return gemfileLockSpecRegex.FindStringSubmatch(lines[i])[2], nil
+}
+
+func (f *gemfileLockVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	lines, i, err := f.find(b)
+	if err != nil {
+		return nil, err
+	}
+	matched := gemfileLockSpecRegex.FindStringSubmatch(lines[i])
+	lines[i] = fmt.Sprintf("    %s (%s)", matched[1], newVersion)
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// find returns the lines of the lockfile and the index of the spec of the gem in the PATH sections
+func (f *gemfileLockVersionFile) find(b []byte) ([]string, int, error) {
+	lines := strings.Split(string(b), "\n")
+	section := ""
+	specs := map[string]int{}
+	names := []string{}
+	for i, line := range lines {
+		if line != "" && !strings.HasPrefix(line, " ") {
+			section = strings.TrimSpace(line)
+			continue
+		}
+		if section != "PATH" {
+			continue
+		}
+		matched := gemfileLockSpecRegex.FindStringSubmatch(line)
+		if matched != nil {
+			specs[matched[1]] = i
+			names = append(names, matched[1])
+		}
+	}
+	if f.gem == "" {
+		if len(names) == 1 {
+			return lines, specs[names[0]], nil
+		}
+		if len(names) == 0 {
+			return nil, 0, errNoVersion
+		}
+		return nil, 0, fmt.Errorf("there is more than one gem in the PATH specs so please choose one of %s with the flag lock-gem", strings.Join(names, ", "))
+	}
+	i, ok := specs[f.gem]
+	if !ok {
+		return nil, 0, errNoVersion
+	}
+	return lines, i, nil
+}
+
+// OpenAPISpec is the part of an OpenAPI or Swagger specification containing the version of the API
+type OpenAPISpec struct {
+	Info struct {
+		Version string `yaml:"version"`
+	} `yaml:"info"`
+}
+
+// openAPIVersionFile handles the info.version of an OpenAPI or Swagger specification in YAML or JSON
+type openAPIVersionFile struct {
+	json bool
+}
+
+func (f *openAPIVersionFile) Read(b []byte) (string, error) {
+	// JSON is also YAML so the one decoder handles both formats
+	var spec OpenAPISpec
+	err := yaml.Unmarshal(b, &spec)
+	if err != nil {
+		return "", err
+	}
+	if spec.Info.Version == "" {
+		return "", errNoVersion
+	}
+	return spec.Info.Version, nil
+}
+
+func (f *openAPIVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	path := []string{"info", "version"}
+	if f.json {
+		return setJSONPathString(b, path, newVersion)
+	}
+	return setYamlPathString(b, path, newVersion)
+}
+
+// AnsibleRoleMeta is the part of the meta/main.yml of an Ansible role which has the version
+type AnsibleRoleMeta struct {
+	GalaxyInfo struct {
+		Version string `yaml:"version"`
+	} `yaml:"galaxy_info"`
+}
+
+// ansibleRoleVersionFile handles the galaxy_info.version of the meta/main.yml of an Ansible role
+type ansibleRoleVersionFile struct{}
+
+func (f *ansibleRoleVersionFile) Read(b []byte) (string, error) {
+	var meta AnsibleRoleMeta
+	err := yaml.Unmarshal(b, &meta)
+	if err != nil {
+		return "", err
+	}
+	if meta.GalaxyInfo.Version == "" {
+		return "", errNoVersion
+	}
+	return meta.GalaxyInfo.Version, nil
+}
+
+func (f *ansibleRoleVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setYamlPathString(b, []string{"galaxy_info", "version"}, newVersion)
+}
+
+// plainVersionFile handles a file such as .version which only contains the version
+type plainVersionFile struct{}
+
+func (f *plainVersionFile) Read(b []byte) (string, error) {
+	v := strings.TrimSpace(string(b))
+	if v == "" {
+		return "", errNoVersion
+	}
+	return v, nil
+}
+
+func (f *plainVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return []byte(newVersion + "\n"), nil
+}
+
+type elmVersionFile struct{}
+
+func (f *elmVersionFile) Read(b []byte) (string, error) {
+	var elmPackage PackageJSON
+	err := json.Unmarshal(b, &elmPackage)
+	if err != nil {
+		return "", err
+	}
+	if elmPackage.Version == "" {
+		return "", errNoVersion
+	}
+	// elm enforces strict semantic versions
+	_, err = semver.Parse(elmPackage.Version)
+	if err != nil {
+		return "", fmt.Errorf("the version %s in %s is not a valid semantic version: %v", elmPackage.Version, elmjson, err)
+	}
+	return elmPackage.Version, nil
+}
+
+func (f *elmVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setJSONStringField(b, "version", newVersion)
+}
+
+type denoVersionFile struct{}
+
+func (f *denoVersionFile) Read(b []byte) (string, error) {
+	var denoConfig PackageJSON
+	err := json.Unmarshal(stripJSONComments(b), &denoConfig)
+	if err != nil {
+		return "", err
+	}
+	if denoConfig.Version == "" {
+		return "", errNoVersion
+	}
+	return denoConfig.Version, nil
+}
+
+func (f *denoVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	// deno.jsonc may contain comments so find the top level version Read uses without them and replace it in place
+	start, end, err := findJSONPathString(stripJSONComments(b), []string{"version"})
+	if err != nil {
+		return nil, err
+	}
+	return replaceJSONString(b, start, end, newVersion)
+}
+
+type bumpversionVersionFile struct{}
+
+func (f *bumpversionVersionFile) Read(b []byte) (string, error) {
+	lines := strings.Split(string(b), "\n")
+	i := findIniKey(lines, "bumpversion", "current_version")
+	if i >= 0 {
+		v := strings.TrimSpace(strings.SplitN(lines[i], "=", 2)[1])
+		if v != "" {
+			return v, nil
+		}
+	}
+	return "", errNoVersion
+}
+
+func (f *bumpversionVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	lines := strings.Split(string(b), "\n")
+	i := findIniKey(lines, "bumpversion", "current_version")
+	if i < 0 {
+		return nil, fmt.Errorf("no current_version found in the [bumpversion] section")
+	}
+	lines[i] = lines[i][:strings.Index(lines[i], "=")+1] + " " + newVersion
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+var (
+	rubySpecVersionRegex = regexp.MustCompile(`(?m)^\s*\w+\.version\s*=\s*(.+?)\s*$`)
+	rubyLiteralRegex     = regexp.MustCompile(`^["']([^"']*)["'](\.freeze)?$`)
+)
+
+// rubySpecVersionFile handles .gemspec and .podspec files, falling back to git tags only if the version is computed
+// rather than a string literal unless a literal is required
+type rubySpecVersionFile struct {
+	requireLiteral bool
+}
+
+func (f *rubySpecVersionFile) Read(b []byte) (string, error) {
+	matched := rubySpecVersionRegex.FindStringSubmatch(string(b))
+	if len(matched) < 2 {
+		return "", errNoVersion
+	}
+	literal := rubyLiteralRegex.FindStringSubmatch(matched[1])
+	if len(literal) > 1 {
+		return literal[1], nil
+	}
+	if f.requireLiteral {
+		return "", fmt.Errorf("the version is computed by %s rather than a string literal, please use a literal such as spec.version = \"1.2.3\" or use the flag use-git-tag-only", matched[1])
+	}
+	log.Warnf("the version is computed by %s so only git tags will be used\n", matched[1])
+	return "", nil
+}
+
+func (f *rubySpecVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	output, literal := setRubySpecVersion(b, newVersion)
+	if !literal {
+		if f.requireLiteral {
+			return nil, fmt.Errorf("the version is not a string literal")
+		}
+		log.Warnf("not updating the version as it is not a string literal\n")
+		return b, nil
+	}
+	return output, nil
+}
+
+// setRubySpecVersion replaces a string literal version in a .gemspec or .podspec, returning false if the
+// version is not a string literal
+func setRubySpecVersion(b []byte, newVersion string) ([]byte, bool) {
+	matched := rubySpecVersionRegex.FindSubmatch(b)
+	if len(matched) < 2 || !rubyLiteralRegex.Match(matched[1]) {
+		return nil, false
+	}
+	regex := regexp.MustCompile(`(?m)^(\s*\w+\.version\s*=\s*)(["'])[^"']*(["'])`)
+	return regex.ReplaceAll(b, []byte("${1}${2}"+newVersion+"${3}")), true
+}
+
+var (
+	gradleRootProjectVersionRegex = regexp.MustCompile(`(?m)^([ \t]*(?:gradle\.)?rootProject\.version[ \t]*=[ \t]*["'])([^"'$\n]+)(["'])`)
+	gradleRootProjectBlockRegex   = regexp.MustCompile(`(rootProject[ \t]*\{[^}]*?\bversion[ \t]*=[ \t]*["'])([^"'$\n]+)(["'])`)
+	gradleRootProjectAnyRegex     = regexp.MustCompile(`rootProject(?:\.version|[ \t]*\{[^}]*?\bversion)[ \t]*=[ \t]*(.*)`)
+)
+
+// gradleSettingsVersionFile handles the version of the root project set in a Gradle settings.gradle or
+// settings.gradle.kts, either as rootProject.version = "1.2.3" or in a gradle.rootProject { version = "1.2.3" } block
+type gradleSettingsVersionFile struct{}
+
+func (f *gradleSettingsVersionFile) Read(b []byte) (string, error) {
+	for _, regex := range []*regexp.Regexp{gradleRootProjectVersionRegex, gradleRootProjectBlockRegex} {
+		matched := regex.FindSubmatch(b)
+		if matched != nil {
+			return string(matched[2]), nil
+		}
+	}
+	if matched := gradleRootProjectAnyRegex.FindSubmatch(b); matched != nil {
+		return "", fmt.Errorf("the root project version is set to %s rather than a string, such as from a property of gradle.properties which can be used with --filename gradle.properties --property-key version instead", strings.TrimSpace(string(matched[1])))
+	}
+	return "", errNoVersion
+}
+
+func (f *gradleSettingsVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	for _, regex := range []*regexp.Regexp{gradleRootProjectVersionRegex, gradleRootProjectBlockRegex} {
+		if loc := regex.FindSubmatchIndex(b); loc != nil {
+			output := append([]byte{}, b[:loc[4]]...)
+			output = append(output, []byte(newVersion)...)
+			return append(output, b[loc[5]:]...), nil
+		}
+	}
+	// report a version which isn't a string the same way as reading it
+	_, err := f.Read(b)
+	return nil, err
+}
+
+var rubyConstantRegex = regexp.MustCompile(`(?m)^([ \t]*VERSION[ \t]*=[ \t]*["'])([^"'\n]*)(["'])`)
+
+// rubyConstantVersionFile handles a top level or namespaced VERSION = "1.2.3" constant in a Ruby source file
+type rubyConstantVersionFile struct{}
+
+func (f *rubyConstantVersionFile) Read(b []byte) (string, error) {
+	matched := rubyConstantRegex.FindSubmatch(b)
+	if len(matched) < 3 || len(matched[2]) == 0 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *rubyConstantVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	if !rubyConstantRegex.Match(b) {
+		return nil, errNoVersion
+	}
+	return rubyConstantRegex.ReplaceAll(b, []byte("${1}"+newVersion+"${3}")), nil
+}
+
+// wixVersionFile handles the Version attribute of the Product (WiX 3) or Package (WiX 4) element of a .wxs file
+type wixVersionFile struct{}
+
+func (f *wixVersionFile) Read(b []byte) (string, error) {
+	v, _, _, err := findWixVersion(b)
+	return v, err
+}
+
+func (f *wixVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	v, start, end, err := findWixVersion(b)
+	if err != nil {
+		return nil, err
+	}
+	// windows installer versions are conventionally 4 parts so keep the revision if the file has one
+	if strings.Count(v, ".") == 3 && strings.Count(newVersion, ".") == 2 {
+		newVersion = newVersion + ".0"
+	}
+	regex := regexp.MustCompile(`(\sVersion\s*=\s*["'])[^"']*(["'])`)
+	element := regex.ReplaceAll(b[start:end], []byte("${1}"+newVersion+"${2}"))
+	output := append([]byte{}, b[:start]...)
+	output = append(output, element...)
+	return append(output, b[end:]...), nil
+}
+
+// dprojVersionFields are the elements of the 4 parts of the version of a Delphi project
+var dprojVersionFields = []string{"VerInfo_MajorVer", "VerInfo_MinorVer", "VerInfo_Release", "VerInfo_Build"}
+
+var dprojKeysVersionRegex = regexp.MustCompile(`((?:^|[>;])(?:FileVersion|ProductVersion)=)[^;<]*`)
+
+// dprojVersionFile handles the 4 part version of a Delphi .dproj which is in separate major, minor, release and build
+// elements as well as the FileVersion and ProductVersion of the VerInfo_Keys, all repeated for each build configuration
+type dprojVersionFile struct{}
+
+func (f *dprojVersionFile) Read(b []byte) (string, error) {
+	parts := []string{}
+	for _, field := range dprojVersionFields {
+		matched := dprojFieldRegex(field).FindSubmatch(b)
+		if matched == nil {
+			parts = append(parts, "0")
+		} else {
+			parts = append(parts, string(matched[2]))
+		}
+	}
+	if parts[0] != "0" || dprojFieldRegex(dprojVersionFields[0]).Match(b) {
+		return strings.Join(parts, "."), nil
+	}
+	// older projects only have the version info keys
+	matched := regexp.MustCompile(`(?:^|[>;])FileVersion=([^;<]+)`).FindSubmatch(b)
+	if matched == nil {
+		return "", errNoVersion
+	}
+	return string(matched[1]), nil
+}
+
+func (f *dprojVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	v, err := version.NewVersion(newVersion)
+	if err != nil {
+		return nil, err
+	}
+	if v.Prerelease() != "" {
+		return nil, fmt.Errorf("a Delphi version cannot be a prerelease such as %s", newVersion)
+	}
+	// a new release starts again at build 0 unless the new version has a build
+	segments := append(v.Segments(), 0)[:4]
+	if !dprojFieldRegex(dprojVersionFields[0]).Match(b) && !dprojKeysVersionRegex.Match(b) {
+		return nil, errNoVersion
+	}
+	output := b
+	for i, field := range dprojVersionFields {
+		output = dprojFieldRegex(field).ReplaceAll(output, []byte(fmt.Sprintf("${1}%d${3}", segments[i])))
+	}
+	fourPart := fmt.Sprintf("%d.%d.%d.%d", segments[0], segments[1], segments[2], segments[3])
+	return dprojKeysVersionRegex.ReplaceAll(output, []byte("${1}"+fourPart)), nil
+}
+
+// dprojFieldRegex matches the element of a version field of a Delphi project
+func dprojFieldRegex(field string) *regexp.Regexp {
+	return regexp.MustCompile(`(<` + field + `>)\s*(\d+)\s*(</` + field + `>)`)
+}
+
+// findWixVersion returns the Version attribute of the first Product or Package element which has one along with the
+// offsets of the start element
+func findWixVersion(b []byte) (string, int64, int64, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", 0, 0, errNoVersion
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok || (element.Name.Local != "Product" && element.Name.Local != "Package") {
+			continue
+		}
+		for _, attr := range element.Attr {
+			if attr.Name.Local != "Version" || attr.Value == "" {
+				continue
+			}
+			if strings.HasPrefix(attr.Value, "$(") || strings.HasPrefix(attr.Value, "!(") {
+				return "", 0, 0, fmt.Errorf("the %s Version is the preprocessor variable %s rather than a version", element.Name.Local, attr.Value)
+			}
+			return attr.Value, start, decoder.InputOffset(), nil
+		}
+	}
+}
+
+// plistVersionFile handles a string key of the top level dictionary of an XML property list such as an Info.plist
+type plistVersionFile struct {
+	key string
+}
+
+func (f *plistVersionFile) Read(b []byte) (string, error) {
+	v, _, _, err := f.find(b)
+	return v, err
+}
+
+func (f *plistVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	_, start, end, err := f.find(b)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = xml.EscapeText(&buf, []byte(newVersion))
+	if err != nil {
+		return nil, err
+	}
+	output := append([]byte{}, b[:start]...)
+	output = append(output, buf.Bytes()...)
+	return append(output, b[end:]...), nil
+}
+
+// find returns the string value of the key along with the offsets of the text of the string element
+func (f *plistVersionFile) find(b []byte) (string, int64, int64, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	depth := 0
+	foundKey := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", 0, 0, fmt.Errorf("no %s key found", f.key)
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			// the top level dictionary is inside the plist element
+			if depth != 3 {
+				foundKey = false
+				continue
+			}
+			if t.Name.Local == "key" {
+				var key string
+				err = decoder.DecodeElement(&key, &t)
+				if err != nil {
+					return "", 0, 0, err
+				}
+				depth--
+				foundKey = strings.TrimSpace(key) == f.key
+				continue
+			}
+			if !foundKey {
+				continue
+			}
+			if t.Name.Local != "string" {
+				return "", 0, 0, fmt.Errorf("the %s value is a %s rather than a string", f.key, t.Name.Local)
+			}
+			start := decoder.InputOffset()
+			var value string
+			err = decoder.DecodeElement(&value, &t)
+			if err != nil {
+				return "", 0, 0, err
+			}
+			end := start + int64(bytes.LastIndex(b[start:decoder.InputOffset()], []byte("</")))
+			if strings.HasPrefix(value, "$(") {
+				return "", 0, 0, fmt.Errorf("the %s is the build setting %s, please update the version in the Xcode project instead", f.key, value)
+			}
+			return value, start, end, nil
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// msbuildPropsVersionFile handles the Version element of a PropertyGroup of a .NET Directory.Build.props, which sets
+// the version of every project of a solution
+type msbuildPropsVersionFile struct{}
+
+func (f *msbuildPropsVersionFile) Read(b []byte) (string, error) {
+	v, _, _, err := findMSBuildVersion(b)
+	return v, err
+}
+
+func (f *msbuildPropsVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	_, start, end, err := findMSBuildVersion(b)
+	if err != nil {
+		return nil, err
+	}
+	return replaceXMLText(b, start, end, newVersion)
+}
+
+// findMSBuildVersion returns the first Version property of a PropertyGroup of the Project along with the offsets of
+// its text
+func findMSBuildVersion(b []byte) (string, int64, int64, error) {
+	value, start, end, err := findXMLText(b, "Project", "PropertyGroup", "Version")
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if strings.HasPrefix(value, "$(") {
+		return "", 0, 0, fmt.Errorf("the Version is the property %s rather than a version", value)
+	}
+	return value, start, end, nil
+}
+
+// findXMLText returns the trimmed text of the first element at the path of element names from the root along with
+// the offsets of the text, or errNoVersion if there is no such element
+func findXMLText(b []byte, path ...string) (string, int64, int64, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	parents := []string{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", 0, 0, errNoVersion
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			parents = append(parents, t.Name.Local)
+			if strings.Join(parents, "/") != strings.Join(path, "/") {
+				continue
+			}
+			start := decoder.InputOffset()
+			var value string
+			err = decoder.DecodeElement(&value, &t)
+			if err != nil {
+				return "", 0, 0, err
+			}
+			end := start + int64(bytes.LastIndex(b[start:decoder.InputOffset()], []byte("</")))
+			// only the text, without surrounding whitespace, is replaced
+			text := string(b[start:end])
+			start += int64(len(text) - len(strings.TrimLeft(text, " \t\r\n")))
+			end -= int64(len(text) - len(strings.TrimRight(text, " \t\r\n")))
+			return strings.TrimSpace(value), start, end, nil
+		case xml.EndElement:
+			parents = parents[:len(parents)-1]
+		}
+	}
+}
+
+// replaceXMLText replaces the text between the offsets with the escaped value
+func replaceXMLText(b []byte, start int64, end int64, value string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := xml.EscapeText(&buf, []byte(value))
+	if err != nil {
+		return nil, err
+	}
+	output := append([]byte{}, b[:start]...)
+	output = append(output, buf.Bytes()...)
+	return append(output, b[end:]...), nil
+}
+
+// cargoVersionFile handles the package version of a Rust Cargo.toml, resolving a version inherited with
+// version.workspace = true from the [workspace.package] of the workspace root
+type cargoVersionFile struct {
+	dir string
+}
+
+func (f *cargoVersionFile) Read(b []byte) (string, error) {
+	if raw, ok := tomlRawValue(b, "package", "version"); ok {
+		if v, ok := tomlString(raw); ok && v != "" {
+			return v, nil
+		}
+		if cargoInheritsWorkspace(raw) {
+			return f.readWorkspaceVersion()
+		}
+	}
+	if raw, ok := tomlRawValue(b, "package", "version.workspace"); ok && raw == "true" {
+		return f.readWorkspaceVersion()
+	}
+	// a virtual manifest at the root of a workspace
+	if raw, ok := tomlRawValue(b, "workspace.package", "version"); ok {
+		if v, ok := tomlString(raw); ok && v != "" {
+			return v, nil
+		}
+	}
+	return "", errNoVersion
+}
+
+func (f *cargoVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	if raw, ok := tomlRawValue(b, "package", "version"); ok {
+		if _, ok := tomlString(raw); ok {
+			return setTomlString(b, "package", "version", newVersion)
+		}
+	}
+	if _, ok := tomlRawValue(b, "workspace.package", "version"); ok {
+		return setTomlString(b, "workspace.package", "version", newVersion)
+	}
+	if f.inheritsWorkspace(b) {
+		return nil, fmt.Errorf("the version is inherited from the workspace so please update the workspace root Cargo.toml instead")
+	}
+	return nil, errNoVersion
+}
+
+// inheritsWorkspace returns true if the package version is set with version.workspace = true
+func (f *cargoVersionFile) inheritsWorkspace(b []byte) bool {
+	raw, ok := tomlRawValue(b, "package", "version")
+	if ok && cargoInheritsWorkspace(raw) {
+		return true
+	}
+	raw, ok = tomlRawValue(b, "package", "version.workspace")
+	return ok && raw == "true"
+}
+
+// tomlVersionFile handles a version string in a TOML table, or at the top level if there is no table such as in the
+// Project.toml of a Julia package. The key defaults to version
+type tomlVersionFile struct {
+	table string
+	key   string
+}
+
+func (f *tomlVersionFile) versionKey() string {
+	if f.key == "" {
+		return "version"
+	}
+	return f.key
+}
+
+func (f *tomlVersionFile) Read(b []byte) (string, error) {
+	raw, _ := tomlRawValue(b, f.table, f.versionKey())
+	v, ok := tomlString(raw)
+	if !ok || v == "" {
+		return "", errNoVersion
+	}
+	return v, nil
+}
+
+func (f *tomlVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return setTomlString(b, f.table, f.versionKey(), newVersion)
+}
+
+// cargoInheritsWorkspace returns true for an inline table value of { workspace = true }
+func cargoInheritsWorkspace(raw string) bool {
+	return regexp.MustCompile(`^\{.*\bworkspace\s*=\s*true\b.*\}$`).MatchString(raw)
+}
+
+// readWorkspaceVersion finds the workspace root above the crate and returns its [workspace.package] version
+func (f *cargoVersionFile) readWorkspaceVersion() (string, error) {
+	dir := filepath.Dir(f.dir)
+	for {
+		filename := filepath.Join(dir, cargotoml)
+		b, err := ioutil.ReadFile(filename)
+		if err == nil && tomlHasTable(b, "workspace") {
+			raw, _ := tomlRawValue(b, "workspace.package", "version")
+			v, ok := tomlString(raw)
+			if !ok || v == "" {
+				return "", fmt.Errorf("no [workspace.package] version found in the workspace root %s", filename)
+			}
+			return v, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("the version is inherited from the workspace but no workspace root Cargo.toml was found above %s", f.dir)
+		}
+		dir = parent
+	}
+}
+
+var (
+	pkgverRegex = regexp.MustCompile(`(?m)^(pkgver=)(["']?)([^"'\s]*)(["']?)`)
+	pkgrelRegex = regexp.MustCompile(`(?m)^(pkgrel=)(["']?)([^"'\s]*)(["']?)`)
+)
+
+// pkgbuildVersionFile handles the pkgver of an Arch Linux PKGBUILD
+type pkgbuildVersionFile struct{}
+
+func (f *pkgbuildVersionFile) Read(b []byte) (string, error) {
+	matched := pkgverRegex.FindSubmatch(b)
+	if len(matched) < 4 || len(matched[3]) == 0 {
+		return "", errNoVersion
+	}
+	return string(matched[3]), nil
+}
+
+func (f *pkgbuildVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	if !pkgverRegex.Match(b) {
+		return nil, errNoVersion
+	}
+	// pkgver must not contain hyphens as they separate the pkgver from the pkgrel
+	pkgver := strings.Replace(newVersion, "-", "_", -1)
+	output := pkgverRegex.ReplaceAll(b, []byte("${1}${2}"+pkgver+"${4}"))
+	// a new upstream version always starts again at the first release of the package
+	return pkgrelRegex.ReplaceAll(output, []byte("${1}${2}1${4}")), nil
+}
+
+var (
+	debianHeaderRegex  = regexp.MustCompile(`^(\S+) \(([^)]+)\) ([^;]+);(.*)$`)
+	debianTrailerRegex = regexp.MustCompile(`^ -- (.+?)  `)
+)
+
+// debianChangelogVersionFile handles the version in the latest entry of a debian/changelog. As the changelog is a
+// history of every release the new version is written by prepending an entry rather than replacing the version
+type debianChangelogVersionFile struct {
+	now func() time.Time
+}
+
+func (f *debianChangelogVersionFile) Read(b []byte) (string, error) {
+	matched := debianHeaderRegex.FindStringSubmatch(firstLine(b))
+	if len(matched) < 3 {
+		return "", errNoVersion
+	}
+	return matched[2], nil
+}
+
+func (f *debianChangelogVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	matched := debianHeaderRegex.FindStringSubmatch(firstLine(b))
+	if len(matched) < 5 {
+		return nil, errNoVersion
+	}
+	pkg, oldVersion, distribution, options := matched[1], matched[2], matched[3], matched[4]
+
+	maintainer := ""
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		trailer := debianTrailerRegex.FindStringSubmatch(scanner.Text())
+		if len(trailer) > 1 {
+			maintainer = trailer[1]
+			break
+		}
+	}
+	if maintainer == "" {
+		return nil, fmt.Errorf("no maintainer line found in the latest changelog entry")
+	}
+
+	// keep packaging the new upstream version the same way, starting again at the first debian revision
+	if strings.Contains(oldVersion, "-") && !strings.Contains(newVersion, "-") {
+		newVersion = newVersion + "-1"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s (%s) %s;%s\n\n", pkg, newVersion, distribution, options)
+	fmt.Fprintf(&buf, "  * Release %s\n\n", newVersion)
+	fmt.Fprintf(&buf, " -- %s  %s\n\n", maintainer, f.now().Format(time.RFC1123Z))
+	buf.Write(b)
+	return buf.Bytes(), nil
+}
+
+// firstLine returns the first line of the given bytes without its line ending
+func firstLine(b []byte) string {
+	i := bytes.IndexByte(b, '\n')
+	if i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimRight(string(b), "\r")
+}
+
+var (
+	rpmVersionRegex = regexp.MustCompile(`(?mi)^(Version:[ \t]*)(\S+)`)
+	rpmReleaseRegex = regexp.MustCompile(`(?mi)^(Release:[ \t]*)([0-9]+)`)
+)
+
+// rpmSpecVersionFile handles the Version tag of an RPM .spec file, keeping the column alignment of the value
+type rpmSpecVersionFile struct {
+	resetRelease bool
+}
+
+func (f *rpmSpecVersionFile) Read(b []byte) (string, error) {
+	matched := rpmVersionRegex.FindSubmatch(b)
+	if len(matched) < 3 {
+		return "", errNoVersion
+	}
+	return string(matched[2]), nil
+}
+
+func (f *rpmSpecVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	loc := rpmVersionRegex.FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, errNoVersion
+	}
+	output := append([]byte{}, b[:loc[4]]...)
+	output = append(output, []byte(newVersion)...)
+	output = append(output, b[loc[5]:]...)
+	if f.resetRelease {
+		output = rpmReleaseRegex.ReplaceAll(output, []byte("${1}1"))
+	}
+	return output, nil
+}
+
+// propertiesVersionFile handles a key in a Java properties file such as version.properties
+type propertiesVersionFile struct {
+	key string
+}
+
+func (f *propertiesVersionFile) Read(b []byte) (string, error) {
+	loader := properties.Loader{
+		Encoding:         properties.UTF8,
+		DisableExpansion: true,
+	}
+	props, err := loader.LoadBytes(b)
+	if err != nil {
+		return "", err
+	}
+	v, ok := props.Get(f.key)
+	if !ok || v == "" {
+		return "", errNoVersion
+	}
+	return v, nil
+}
+
+func (f *propertiesVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	regex := regexp.MustCompile(`(?m)^(\s*` + regexp.QuoteMeta(f.key) + `(\s*[=:]\s*|\s+))(.*?)(\r?)$`)
+	if !regex.Match(b) {
+		return nil, fmt.Errorf("no %s property found", f.key)
+	}
+	return regex.ReplaceAll(b, []byte("${1}"+newVersion+"${4}")), nil
+}
+
+// hclVersionFile handles a string assignment to an attribute in a Terraform file
+type hclVersionFile struct {
+	attribute string
+}
+
+func (f *hclVersionFile) Read(b []byte) (string, error) {
+	matched := hclAttributeRegex(f.attribute).FindSubmatch(b)
+	if len(matched) > 2 && len(matched[2]) > 0 {
+		return string(matched[2]), nil
+	}
+	return "", errNoVersion
+}
+
+func (f *hclVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	// only the first assignment is the one Read returns, later ones may be provider or module pins
+	loc := hclAttributeRegex(f.attribute).FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, fmt.Errorf("no %s attribute found", f.attribute)
+	}
+	answer := append([]byte{}, b[:loc[4]]...)
+	answer = append(answer, newVersion...)
+	return append(answer, b[loc[5]:]...), nil
+}
+
+// hclAttributeRegex matches a string assignment to the HCL attribute, capturing the prefix, value and closing quote
+func hclAttributeRegex(attribute string) *regexp.Regexp {
+	if attribute == "" {
+		attribute = "version"
+	}
+	return regexp.MustCompile(`(?m)(^\s*` + regexp.QuoteMeta(attribute) + `\s*=\s*")([^"]*)(")`)
+}
+
+// findCallArguments returns the text between the balanced parentheses of the first call to the given function name
+func findCallArguments(text string, name string) string {
+	start, end := findCallArgumentsIndex(text, regexp.QuoteMeta(name))
+	if start < 0 {
+		return ""
+	}
+	return text[start:end]
+}
+
+// findCallArgumentsIndex returns the offsets of the text between the balanced parentheses of the first call to a
+// function whose name matches the pattern, or -1 if there is no call
+func findCallArgumentsIndex(text string, namePattern string) (int, int) {
+	regex := regexp.MustCompile(`(?m)(^|[^\w])(` + namePattern + `)\s*\(`)
+	loc := regex.FindStringIndex(text)
+	if loc == nil {
+		return -1, -1
+	}
+	depth := 1
+	start := loc[1]
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return start, i
+			}
+		}
+	}
+	return start, len(text)
+}
+
+// findM4Arguments returns the unquoted arguments of the first call to the given m4 macro, taking care of the
+// square bracket quoting so that commas and parentheses inside quotes are not treated as delimiters
+func findM4Arguments(text string, macro string) []string {
+	regex := regexp.MustCompile(`(?m)(^|[^\w])` + regexp.QuoteMeta(macro) + `\(`)
+	loc := regex.FindStringIndex(text)
+	if loc == nil {
+		return nil
+	}
+	args := []string{}
+	var current bytes.Buffer
+	quote := 0
+	depth := 1
+	for i := loc[1]; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '[':
+			if quote > 0 {
+				current.WriteByte(c)
+			}
+			quote++
+			continue
+		case c == ']' && quote > 0:
+			quote--
+			if quote > 0 {
+				current.WriteByte(c)
+			}
+			continue
+		case quote > 0:
+			current.WriteByte(c)
+			continue
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return append(args, strings.TrimSpace(current.String()))
+			}
+		case c == ',' && depth == 1:
+			args = append(args, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	return append(args, strings.TrimSpace(current.String()))
+}
+
+// findIniKey returns the index of the line assigning the key within the given INI section or -1 if it is not found
+func findIniKey(lines []string, section string, key string) int {
+	current := ""
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if current != section {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+var (
+	tomlTableRegex = regexp.MustCompile(`^\s*\[\[?\s*([^\[\]]*?)\s*\]\]?\s*(#.*)?$`)
+	tomlKeyRegex   = regexp.MustCompile(`^\s*([A-Za-z0-9_.\- ]+?)\s*=\s*(.*?)\s*$`)
+)
+
+// tomlRawValue returns the unparsed value of a key in a TOML table, where the key may be dotted such as
+// version.workspace. Only single line values are supported which is enough for the version of a manifest
+func tomlRawValue(b []byte, table string, key string) (string, bool) {
+	current := ""
+	for _, line := range strings.Split(string(b), "\n") {
+		matched := tomlTableRegex.FindStringSubmatch(line)
+		if len(matched) > 1 {
+			current = strings.Replace(matched[1], " ", "", -1)
+			continue
+		}
+		if current != table {
+			continue
+		}
+		matched = tomlKeyRegex.FindStringSubmatch(line)
+		if len(matched) > 2 && strings.Replace(matched[1], " ", "", -1) == key {
+			return matched[2], true
+		}
+	}
+	return "", false
+}
+
+// tomlHasTable returns true if the TOML document declares the table
+func tomlHasTable(b []byte, table string) bool {
+	for _, line := range strings.Split(string(b), "\n") {
+		matched := tomlTableRegex.FindStringSubmatch(line)
+		if len(matched) > 1 && strings.Replace(matched[1], " ", "", -1) == table {
+			return true
+		}
+	}
+	return false
+}
+
+// tomlString returns the contents of a raw TOML basic or literal string value, ignoring any trailing comment
+func tomlString(raw string) (string, bool) {
+	if len(raw) < 2 || (raw[0] != '"' && raw[0] != '\'') {
+		return "", false
+	}
+	end := strings.IndexByte(raw[1:], raw[0])
+	if end < 0 {
+		return "", false
+	}
+	return raw[1 : end+1], true
+}
+
+// setTomlString replaces the string value of a key in a TOML table such as [package] or [workspace.package],
+// preserving the formatting and comments of the rest of the file
+func setTomlString(b []byte, table string, key string, value string) ([]byte, error) {
+	keyRegex := regexp.MustCompile(`^(\s*` + regexp.QuoteMeta(key) + `\s*=\s*["'])([^"']*)(["'])`)
+	lines := strings.Split(string(b), "\n")
+	current := ""
+	for i, line := range lines {
+		matched := tomlTableRegex.FindStringSubmatch(line)
+		if len(matched) > 1 {
+			current = strings.Replace(matched[1], " ", "", -1)
+			continue
+		}
+		if current == table && keyRegex.MatchString(line) {
+			lines[i] = keyRegex.ReplaceAllString(line, "${1}"+value+"${3}")
+			return []byte(strings.Join(lines, "\n")), nil
+		}
+	}
+	if table == "" {
+		return nil, fmt.Errorf("no top level %s found", key)
+	}
+	return nil, fmt.Errorf("no %s found in [%s]", key, table)
+}
+
+// setJSONStringField replaces the first string value of the field, preserving the formatting of the rest of the file
+func setJSONStringField(b []byte, field string, value string) ([]byte, error) {
+	regex := regexp.MustCompile(`("` + regexp.QuoteMeta(field) + `"\s*:\s*")([^"]*)(")`)
+	loc := regex.FindSubmatchIndex(b)
+	if loc == nil {
+		return nil, fmt.Errorf("no %s field found", field)
+	}
+	output := append([]byte{}, b[:loc[4]]...)
+	output = append(output, []byte(value)...)
+	return append(output, b[loc[5]:]...), nil
+}
+
+// setTopLevelJSONStringField replaces the string value of a field of the top level object, ignoring fields of the
+// same name in nested objects and preserving the formatting of the rest of the file
+func setTopLevelJSONStringField(b []byte, field string, value string) ([]byte, error) {
+	return setJSONPathString(b, []string{field}, value)
+}
+
+// setJSONPathString replaces the string value at the path of keys of nested objects, e.g. info then version,
+// preserving the formatting of the rest of the file
+func setJSONPathString(b []byte, path []string, value string) ([]byte, error) {
+	start, end, err := findJSONPathString(b, path)
+	if err != nil {
+		return nil, err
+	}
+	return replaceJSONString(b, start, end, value)
+}
+
+// replaceJSONString replaces the quoted string between the offsets with the value
+func replaceJSONString(b []byte, start int64, end int64, value string) ([]byte, error) {
+	quoted, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	output := append([]byte{}, b[:start]...)
+	output = append(output, quoted...)
+	return append(output, b[end:]...), nil
+}
+
+// findJSONPathString returns the offsets of the quoted string value at the path of keys of nested objects
+func findJSONPathString(b []byte, path []string) (int64, int64, error) {
+	type frame struct {
+		object    bool
+		expectKey bool
+		key       string
+	}
+	stack := []*frame{}
+	matches := func() bool {
+		if len(stack) != len(path) {
+			return false
+		}
+		for i, f := range stack {
+			if !f.object || f.key != path[i] {
+				return false
+			}
+		}
+		return true
+	}
+	// valueDone records that the value of the current key of an object has been consumed
+	valueDone := func() {
+		if len(stack) > 0 && stack[len(stack)-1].object {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return 0, 0, fmt.Errorf("no %s field found", strings.Join(path, "."))
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if d, ok := token.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				stack = append(stack, &frame{object: d == '{', expectKey: d == '{'})
+			} else {
+				stack = stack[:len(stack)-1]
+				valueDone()
+			}
+			continue
+		}
+		if len(stack) == 0 {
+			continue
+		}
+		top := stack[len(stack)-1]
+		if !top.object || !top.expectKey {
+			valueDone()
+			continue
+		}
+		top.key, _ = token.(string)
+		top.expectKey = false
+		if !matches() {
+			continue
+		}
+		keyEnd := decoder.InputOffset()
+		valueToken, err := decoder.Token()
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, ok := valueToken.(string); !ok {
+			return 0, 0, fmt.Errorf("the %s field is not a string", strings.Join(path, "."))
+		}
+		valueEnd := decoder.InputOffset()
+		valueStart := keyEnd + int64(bytes.IndexByte(b[keyEnd:valueEnd], '"'))
+		return valueStart, valueEnd, nil
+	}
+}
+
+var (
+	yamlKeyRegex    = regexp.MustCompile(`^(\s*)("[^"]*"|'[^']*'|[^\s:#\-][^:#]*?)\s*:(\s+|$)(.*)$`)
+	yamlScalarRegex = regexp.MustCompile(`^(["']?)([^"'#]*?)(["']?)(\s+#.*)?$`)
+)
+
+// setYamlPathString replaces the scalar value at the path of keys of nested block mappings, e.g. info then version,
+// preserving the formatting, quoting and comments of the rest of the document
+func setYamlPathString(b []byte, path []string, value string) ([]byte, error) {
+	lines, i, scalar, err := findYamlPath(b, path)
+	if err != nil {
+		return nil, err
+	}
+	lines[i] = scalar[0] + scalar[1] + value + scalar[3] + scalar[4]
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// getYamlPathString returns the raw text of the scalar value at the path of keys of nested block mappings, so a
+// version such as 1.10 isn't read as a number
+func getYamlPathString(b []byte, path []string) (string, error) {
+	_, _, scalar, err := findYamlPath(b, path)
+	if err != nil {
+		return "", err
+	}
+	return scalar[2], nil
+}
+
+// findYamlPath returns the lines of the document, the index of the line with the scalar value at the path and the
+// parts of that line: the text before the value, any opening quote, the value, any closing quote and any comment
+func findYamlPath(b []byte, path []string) ([]string, int, []string, error) {
+	lines := strings.Split(string(b), "\n")
+	depth := 0
+	parentIndent := -1
+	indent := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+		if lineIndent <= parentIndent {
+			// left the mapping of the matched parent key without finding the child key
+			break
+		}
+		if indent < 0 {
+			indent = lineIndent
+		}
+		if lineIndent != indent {
+			continue
+		}
+		matched := yamlKeyRegex.FindStringSubmatch(line)
+		if matched == nil || strings.Trim(matched[2], `"'`) != path[depth] {
+			continue
+		}
+		if depth < len(path)-1 {
+			depth++
+			parentIndent = lineIndent
+			indent = -1
+			continue
+		}
+		rest := matched[4]
+		scalar := yamlScalarRegex.FindStringSubmatch(rest)
+		if scalar == nil || scalar[2] == "" || strings.ContainsAny(rest[:1], "&*|>{[") {
+			return nil, 0, nil, fmt.Errorf("the %s is not a plain scalar value", strings.Join(path, "."))
+		}
+		scalar[0] = line[:len(line)-len(rest)]
+		return lines, i, scalar, nil
+	}
+	return nil, 0, nil, fmt.Errorf("no %s found", strings.Join(path, "."))
+}
+
+// stripJSONComments blanks out the comments and trailing commas allowed in JSONC so it can be parsed as JSON, keeping
+// every other byte at the same offset
+func stripJSONComments(b []byte) []byte {
+	var buf bytes.Buffer
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case inString:
+			buf.WriteByte(c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				buf.WriteByte(b[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			buf.WriteByte(c)
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				buf.WriteByte(' ')
+				i++
+			}
+			if i < len(b) {
+				buf.WriteByte('\n')
+			}
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			end := len(b)
+			if n := bytes.Index(b[i+2:], []byte("*/")); n >= 0 {
+				end = i + 2 + n + 2
+			}
+			for ; i < end; i++ {
+				if b[i] == '\n' {
+					buf.WriteByte('\n')
+				} else {
+					buf.WriteByte(' ')
+				}
+			}
+			i--
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	trailingCommas := regexp.MustCompile(`,(\s*[}\]])`)
+	return trailingCommas.ReplaceAll(buf.Bytes(), []byte(" $1"))
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+const (
+	strategyPatch        = "patch"
+	strategyMinor        = "minor"
+	strategyMajor        = "major"
+	strategyConventional = "conventional"
+)
+
+// IncrementStrategy decides the next version from the latest tag version and the base version of the version file,
+// which is nil if there is no version file
+type IncrementStrategy interface {
+	NextVersion(latest semver.Version, base *semver.Version) (semver.Version, error)
+}
+
+// IncrementStrategyFactory creates the IncrementStrategy for the options and the version of the latest tag
+type IncrementStrategyFactory func(o *StepNextVersionOptions, latest string) (IncrementStrategy, error)
+
+var incrementStrategyFactories = map[string]IncrementStrategyFactory{}
+
+// RegisterIncrementStrategy registers the factory of the IncrementStrategy selected with the flag strategy
+func RegisterIncrementStrategy(name string, factory IncrementStrategyFactory) {
+	incrementStrategyFactories[name] = factory
+}
+
+// supportedIncrementStrategies returns the sorted names of the registered increment strategies
+func supportedIncrementStrategies() []string {
+	names := []string{}
+	for name := range incrementStrategyFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterIncrementStrategy(strategyPatch, func(o *StepNextVersionOptions, latest string) (IncrementStrategy, error) {
+		return &bumpStrategy{bump: strategyPatch}, nil
+	})
+	RegisterIncrementStrategy(strategyMinor, func(o *StepNextVersionOptions, latest string) (IncrementStrategy, error) {
+		return &bumpStrategy{bump: strategyMinor}, nil
+	})
+	RegisterIncrementStrategy(strategyMajor, func(o *StepNextVersionOptions, latest string) (IncrementStrategy, error) {
+		return &bumpStrategy{bump: strategyMajor}, nil
+	})
+	RegisterIncrementStrategy(strategyConventional, func(o *StepNextVersionOptions, latest string) (IncrementStrategy, error) {
+		tag, err := o.versionTag(latest)
+		if err != nil {
+			return nil, err
+		}
+		messages, err := o.commitMessagesSince(tag)
+		if err != nil {
+			return nil, err
+		}
+		return &conventionalStrategy{
+			messages:       messages,
+			respectZerover: o.RespectZerover,
+		}, nil
+	})
+}
+
+// bumpStrategy always increments the same part of the latest version
+type bumpStrategy struct {
+	bump string
+}
+
+func (s *bumpStrategy) NextVersion(latest semver.Version, base *semver.Version) (semver.Version, error) {
+	return bumpVersion(latest, base, s.bump), nil
+}
+
+// conventionalStrategy increments the major version if any commit since the latest tag is a breaking change, the
+// minor version if any is a feature or else the patch version, see https://www.conventionalcommits.org/. To respect
+// zerover a breaking change only increments the minor version of a 0.y.z version as anything may change before 1.0.0
+type conventionalStrategy struct {
+	messages       []string
+	respectZerover bool
+}
+
+func (s *conventionalStrategy) NextVersion(latest semver.Version, base *semver.Version) (semver.Version, error) {
+	bump := conventionalBump(s.messages)
+	if bump == strategyMajor && s.respectZerover && latest.Major == 0 {
+		bump = strategyMinor
+	}
+	return bumpVersion(latest, base, bump), nil
+}
+
+var (
+	conventionalHeaderRegex   = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?:`)
+	conventionalBreakingRegex = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:`)
+)
+
+// conventionalBump returns the part of the version the conventional commit messages increment
+func conventionalBump(messages []string) string {
+	bump := strategyPatch
+	for _, message := range messages {
+		header := conventionalHeaderRegex.FindStringSubmatch(strings.TrimSpace(message))
+		if (header != nil && header[3] == "!") || conventionalBreakingRegex.MatchString(message) {
+			return strategyMajor
+		}
+		if header != nil && header[1] == "feat" {
+			bump = strategyMinor
+		}
+	}
+	return bump
+}
+
+// bumpVersion increments the part of the latest version. If the base version from the version file is ahead of the
+// incremented version it is used instead
+func bumpVersion(latest semver.Version, base *semver.Version, bump string) semver.Version {
+	var next semver.Version
+	switch bump {
+	case strategyMajor:
+		next = semver.Version{Major: latest.Major + 1}
+	case strategyMinor:
+		next = semver.Version{Major: latest.Major, Minor: latest.Minor + 1}
+	default:
+		return nextVersionFromTag(latest, base)
+	}
+	if base != nil && base.Compare(next) > 0 {
+		return semver.Version{Major: base.Major, Minor: base.Minor, Patch: base.Patch}
+	}
+	return next
+}
+
+// versionTag returns the name of the tag of the version, which may or may not have a v prefix, or an empty string if
+// there is no such tag
+func (o *StepNextVersionOptions) versionTag(v string) (string, error) {
+	tags := []string{o.tagPrefix() + v}
+	if o.TagPrefix == "" {
+		tags = append(tags, "v"+v, v)
+	}
+	for _, tag := range tags {
+		exists, err := o.tagExists(tag)
+		if err != nil || exists {
+			return tag, err
+		}
+	}
+	return "", nil
+}
+
+// commitMessagesSince returns the messages of the commits since the tag, or of all commits if there is no tag
+func (o *StepNextVersionOptions) commitMessagesSince(tag string) ([]string, error) {
+	args := []string{"log", "--format=%B%x00"}
+	if tag != "" {
+		args = append(args, tag+"..HEAD")
+	}
+	out, err := o.getCommandOutput("", "git", args...)
+	if err != nil {
+		return nil, err
+	}
+	messages := []string{}
+	for _, message := range strings.Split(out, "\x00") {
+		if strings.TrimSpace(message) != "" {
+			messages = append(messages, strings.TrimSpace(message))
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBumpStrategies(t *testing.T) {
+	latest := semver.MustParse("1.2.3")
+	ahead := semver.MustParse("2.1.0")
+	tests := []struct {
+		strategy IncrementStrategy
+		base     *semver.Version
+		expected string
+	}{
+		{&bumpStrategy{bump: strategyPatch}, nil, "1.2.4"},
+		{&bumpStrategy{bump: strategyMinor}, nil, "1.3.0"},
+		{&bumpStrategy{bump: strategyMajor}, nil, "2.0.0"},
+		{&bumpStrategy{bump: strategyMajor}, &ahead, "2.1.0"},
+		{&conventionalStrategy{messages: []string{"fix: typo"}}, nil, "1.2.4"},
+		{&conventionalStrategy{messages: []string{"fix: typo", "feat(api): add versions"}}, nil, "1.3.0"},
+		{&conventionalStrategy{messages: []string{"feat!: drop the v1 api"}}, nil, "2.0.0"},
+		{&conventionalStrategy{messages: []string{"refactor: config\n\nBREAKING CHANGE: the config file is renamed"}}, nil, "2.0.0"},
+		{&conventionalStrategy{messages: []string{"updated the readme"}}, nil, "1.2.4"},
+		{&conventionalStrategy{messages: []string{"feat!: drop the v1 api"}, respectZerover: true}, nil, "2.0.0"},
+	}
+	for _, test := range tests {
+		v, err := test.strategy.NextVersion(latest, test.base)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, v.String(), "strategy %#v", test.strategy)
+	}
+}
+
+func TestRespectZerover(t *testing.T) {
+	latest := semver.MustParse("0.4.2")
+	breaking := []string{"fix!: the config file is renamed"}
+
+	s := conventionalStrategy{messages: breaking}
+	v, err := s.NextVersion(latest, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", v.String())
+
+	s.respectZerover = true
+	v, err = s.NextVersion(latest, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.5.0", v.String(), "a breaking change of a 0.y.z version only increments the minor version")
+}
+
+func TestConventionalStrategy(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-conventional-strategy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "feat: the first feature")
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "tag", "v0.1.0")
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "fix: a bug in the first feature")
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		UseGitTagOnly: true,
+		Strategy:      strategyConventional,
+	}
+	v, _, err := o.getNewVersionFromTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1.1", v, "only the fix since the tag counts")
+
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "feat: a second feature")
+	assert.NoError(t, err)
+	v, _, err = o.getNewVersionFromTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "0.2.0", v)
+
+	o.Strategy = "random"
+	_, _, err = o.getNewVersionFromTag()
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+}
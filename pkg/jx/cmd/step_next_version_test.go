@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"testing"
+	"time"
 
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 
+	"github.com/blang/semver"
 	"github.com/jenkins-x/jx/pkg/gits"
 	"github.com/jenkins-x/jx/pkg/tests"
 	"github.com/jenkins-x/jx/pkg/util"
@@ -27,24 +33,2835 @@ func TestMakefile(t *testing.T) {
 	assert.Equal(t, "1.2.0-SNAPSHOT", v, "error with getVersion for a Makefile")
 }
 
+func TestMakefileMultipleOccurrences(t *testing.T) {
+	b, err := ioutil.ReadFile("test_data/next_version/make/Makefile.multi")
+	assert.NoError(t, err)
+
+	f := keyAssignmentVersionFile{keys: []string{"VERSION", "IMAGE_TAG"}}
+	v, err := f.Read(b)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", v)
+
+	output, err := f.Write(b, "1.3.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(b), "1.2.0", "1.3.0", -1), string(output), "every occurrence should be updated")
+	assert.Contains(t, string(output), "VERSION_FILE := VERSION")
+	assert.Contains(t, string(output), "$(NAME):$(VERSION)")
+}
+
+func TestMakefileShellAssignments(t *testing.T) {
+	b, err := ioutil.ReadFile("test_data/next_version/make/Makefile.shell")
+	assert.NoError(t, err)
+
+	f := keyAssignmentVersionFile{keys: []string{"VERSION"}}
+	_, err = f.Read(b)
+	assert.Equal(t, errNoVersion, err, "a := or ?= assignment of $(shell ...) is not a version")
+
+	_, err = f.Write(b, "1.3.0")
+	assert.Equal(t, errNoVersion, err)
+
+	f = keyAssignmentVersionFile{keys: []string{"VERSION", "IMAGE_TAG"}}
+	v, err := f.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", v)
+
+	output, err := f.Write(b, "1.3.0")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(b), "IMAGE_TAG := 1.2.0", "IMAGE_TAG := 1.3.0", 1), string(output), "the $(shell ...) assignments should be left untouched")
+}
+
+func TestDockerfile(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/docker",
+		Filename: "Dockerfile",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.3.4", v, "error with getVersion for a Dockerfile")
+
+	b, err := ioutil.ReadFile("test_data/next_version/docker/Dockerfile")
+	assert.NoError(t, err)
+
+	f := keyAssignmentVersionFile{keys: []string{"VERSION", "version"}}
+	output, err := f.Write(b, "2.4.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "FROM alpine:3.8\nARG VERSION=2.4.0\nENV VERSION ${VERSION}\nLABEL version=\"2.4.0\"\nENV APP_VERSION=2.3.4\nRUN echo \"building $VERSION\"\n", string(output))
+}
+
+func TestSetTopLevelJSONStringField(t *testing.T) {
+	b := []byte(`{
+  "name": "my-app",
+  "engines": {"version": "8.0.0"},
+  "files": ["a", {"version": "1"}],
+  "private": true,
+  "version": "0.0.1"
+}`)
+
+	output, err := setTopLevelJSONStringField(b, "version", "0.0.2")
+
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(b), "0.0.1", "0.0.2", 1), string(output))
+
+	_, err = setTopLevelJSONStringField([]byte(`{"engines": {"version": "8.0.0"}}`), "version", "0.0.2")
+
+	assert.Error(t, err)
+}
+
 func TestPomXML(t *testing.T) {
 
 	o := StepNextVersionOptions{
-		Dir:      "test_data/next_version/java",
-		Filename: "pom.xml",
+		Dir:      "test_data/next_version/java",
+		Filename: "pom.xml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.0-SNAPSHOT", v, "error with getVersion for a pom.xml")
+}
+
+func TestPomXMLRevision(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/maven-ci",
+		Filename: "pom.xml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3-SNAPSHOT", v, "error with getVersion for a pom.xml with a CI friendly version")
+
+	b, err := ioutil.ReadFile("test_data/next_version/maven-ci/pom.xml")
+	assert.NoError(t, err)
+
+	f := pomVersionFile{}
+	output, err := f.Write(b, "1.3.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "<revision>1.2.3</revision>", "<revision>1.3.0</revision>", 1)
+	assert.Equal(t, expected, string(output), "only the revision property should be updated")
+
+	b, err = ioutil.ReadFile("test_data/next_version/java/pom.xml")
+	assert.NoError(t, err)
+	_, err = f.Write(b, "1.3.0")
+	assert.Equal(t, errReadOnlyVersionFile, err, "only a CI friendly version can be updated")
+}
+
+func TestChart(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/helm",
+		Filename: "Chart.yaml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.0.1-SNAPSHOT", v, "error with getVersion for a pom.xml")
+}
+
+func TestPackageJSONWorkspace(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:       "test_data/next_version/workspaces",
+		Filename:  "package.json",
+		Workspace: "my-lib",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.1.0", v, "error with getVersion for a package.json workspace")
+}
+
+func TestMelosWorkspace(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/melos",
+		Filename: "melos.yaml",
+		Package:  "my_core",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.4.1", v, "error with getVersion for a Melos workspace package")
+
+	o.Package = "my_app"
+	file, err := o.versionFile()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("apps", "my_app", "pubspec.yaml"), file)
+
+	v, err = o.getVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3+7", v)
+
+	o.Package = "missing"
+	_, err = o.getVersion()
+	assert.Error(t, err)
+
+	assert.True(t, matchesMelosPackages([]string{"packages/**"}, "packages/a/b"))
+	assert.False(t, matchesMelosPackages([]string{"apps/*"}, "apps/a/b"))
+}
+
+func TestProjectClj(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/clojure",
+		Filename: "project.clj",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3-SNAPSHOT", v, "error with getVersion for a project.clj")
+}
+
+func TestMesonBuild(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/meson",
+		Filename: "meson.build",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a meson.build")
+}
+
+func TestConfigureAc(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/autotools",
+		Filename: "configure.ac",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a configure.ac")
+}
+
+func TestPackageSwift(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/swift",
+		Filename: "Package.swift",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", v, "Package.swift should only use git tags")
+}
+
+func TestSnapcraftYaml(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/snap",
+		Filename: "snapcraft.yaml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.4.1", v, "error with getVersion for a snapcraft.yaml")
+}
+
+func TestGemspec(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/ruby",
+		Filename: "my_gem.gemspec",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.3.2", v, "error with getVersion for a gemspec")
+}
+
+func TestPodspecComputedVersion(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/ruby",
+		Filename: "MyPod.podspec",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", v, "a computed podspec version should fall back to git tags")
+
+	o.RequireLiteral = true
+	_, err = o.getVersion()
+
+	assert.Error(t, err)
+}
+
+func TestRubyConstant(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/ruby",
+		Filename: "lib/my_tool/version.rb",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.9.2", v, "error with getVersion for a Ruby VERSION constant")
+
+	f := rubyConstantVersionFile{}
+	b, err := f.Write([]byte("module MyTool\n  VERSION = '0.9.2'\n  API_VERSION = '2'\nend\n"), "0.10.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "module MyTool\n  VERSION = '0.10.0'\n  API_VERSION = '2'\nend\n", string(b))
+}
+
+func TestWix(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/wix",
+		Filename: "Product.wxs",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.4.2.0", v, "error with getVersion for a .wxs")
+
+	b, err := ioutil.ReadFile("test_data/next_version/wix/Product.wxs")
+	assert.NoError(t, err)
+
+	f := wixVersionFile{}
+	output, err := f.Write(b, "1.5.0")
+
+	assert.NoError(t, err)
+
+	expected := strings.Replace(string(b), `Version="1.4.2.0"`, `Version="1.5.0.0"`, 1)
+	assert.Equal(t, expected, string(output))
+
+	_, err = f.Read([]byte(`<Wix><Product Id="*" Version="$(var.Version)" /></Wix>`))
+
+	assert.Error(t, err)
+}
+
+func TestInfoPlist(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/plist",
+		Filename: "Info.plist",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.1.0", v, "error with getVersion for an Info.plist")
+
+	o.PlistKey = "CFBundleVersion"
+	v, err = o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "42", v, "error with getVersion for an Info.plist CFBundleVersion")
+
+	b, err := ioutil.ReadFile("test_data/next_version/plist/Info.plist")
+	assert.NoError(t, err)
+
+	f := plistVersionFile{key: plistShortVersion}
+	output, err := f.Write(b, "2.2.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), "<string>2.1.0</string>", "<string>2.2.0</string>", 1), string(output))
+
+	_, err = f.Read([]byte(`<plist><dict><key>CFBundleShortVersionString</key><string>$(MARKETING_VERSION)</string></dict></plist>`))
+
+	assert.Error(t, err)
+}
+
+func TestCargoWorkspace(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/cargo",
+		Filename: "crates/my-cli/Cargo.toml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.8.1", v, "error with getVersion for a Cargo workspace member")
+
+	o.Filename = "Cargo.toml"
+	v, err = o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.8.1", v, "error with getVersion for a Cargo workspace root")
+
+	b, err := ioutil.ReadFile("test_data/next_version/cargo/Cargo.toml")
+	assert.NoError(t, err)
+
+	f := cargoVersionFile{}
+	output, err := f.Write(b, "0.9.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), `version = "0.8.1"`, `version = "0.9.0"`, 1), string(output))
+
+	output, err = f.Write([]byte("[package]\nname = \"my-crate\"\nversion = \"1.0.0\"\n\n[dependencies]\nlog = { version = \"0.4\" }\n"), "1.0.1")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "[package]\nname = \"my-crate\"\nversion = \"1.0.1\"\n\n[dependencies]\nlog = { version = \"0.4\" }\n", string(output))
+}
+
+func TestKustomization(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:            "test_data/next_version/kustomize",
+		Filename:       "kustomization.yaml",
+		KustomizeImage: "gcr.io/my-org/my-app",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.3.2", v, "error with getVersion for a kustomization.yaml image")
+
+	b, err := ioutil.ReadFile("test_data/next_version/kustomize/kustomization.yaml")
+	assert.NoError(t, err)
+
+	f := kustomizeVersionFile{image: "gcr.io/my-org/my-app"}
+	output, err := f.Write(b, "0.4.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), "newTag: 0.3.2", "newTag: 0.4.0", 1), string(output))
+
+	f = kustomizeVersionFile{image: "redis"}
+	_, err = f.Write(b, "0.4.0")
+
+	assert.Error(t, err)
+
+	f = kustomizeVersionFile{image: "nginx"}
+	output, err = f.Write([]byte("images:\n- name: nginx\n  newName: my-nginx\n"), "1.16.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "images:\n- name: nginx\n  newName: my-nginx\n  newTag: 1.16.0\n", string(output))
+}
+
+func TestKustomizationKeepsComments(t *testing.T) {
+	b, err := ioutil.ReadFile("test_data/next_version/kustomize_comments/kustomization.yaml")
+	assert.NoError(t, err)
+
+	f := kustomizeVersionFile{image: "gcr.io/my-org/my-app"}
+	v, err := f.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.3.2", v)
+
+	output, err := f.Write(b, "0.4.0")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(b), `newTag: "0.3.2" # released by jx`, `newTag: "0.4.0" # released by jx`, 1), string(output))
+
+	f = kustomizeVersionFile{image: "busybox"}
+	output, err = f.Write(b, "1.36")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "d3d3\n", "d3d3\n    newTag: 1.36\n", 1)
+	assert.Equal(t, expected, string(output), "the newTag should be added to the entry of the image")
+}
+
+func TestDockerCompose(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:            "test_data/next_version/compose",
+		Filename:       "docker-compose.yml",
+		ComposeService: "app",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a docker-compose.yml")
+
+	b, err := ioutil.ReadFile("test_data/next_version/compose/docker-compose.yml")
+	assert.NoError(t, err)
+
+	f := composeVersionFile{service: "app"}
+	output, err := f.Write(b, "1.2.4")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "myorg/app:1.2.3", "myorg/app:1.2.4", 1)
+	assert.Equal(t, expected, string(output))
+
+	f = composeVersionFile{service: "worker"}
+	_, err = f.Read(b)
+	assert.Equal(t, errNoVersion, err)
+
+	output, err = f.Write(b, "1.2.4")
+	assert.NoError(t, err)
+	expected = strings.Replace(string(b), "image: myorg/worker", "image: myorg/worker:1.2.4", 1)
+	assert.Equal(t, expected, string(output))
+
+	f = composeVersionFile{service: "cache"}
+	_, err = f.Read(b)
+	assert.Error(t, err)
+
+	_, err = f.Write([]byte("app:\n  image: myorg/app:0.1.0\n"), "0.2.0")
+	assert.Error(t, err, "the service name is app not cache")
+
+	f = composeVersionFile{service: "app"}
+	output, err = f.Write([]byte("app:\n  image: myorg/app:0.1.0\n"), "0.2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "app:\n  image: myorg/app:0.2.0\n", string(output))
+}
+
+func TestAnsibleRoleMeta(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/ansible",
+		Filename: "meta/main.yml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.0.1", v, "error with getVersion for an Ansible role meta/main.yml")
+
+	b, err := ioutil.ReadFile("test_data/next_version/ansible/meta/main.yml")
+	assert.NoError(t, err)
+
+	f := ansibleRoleVersionFile{}
+	output, err := f.Write(b, "2.1.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `version: "2.0.1"`, `version: "2.1.0"`, 1)
+	assert.Equal(t, expected, string(output), "only the galaxy_info version should change")
+
+	assert.Nil(t, o.versionFileFor("tasks/main.yml"), "only the main.yml in the meta directory is the role metadata")
+}
+
+func TestNix(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/nix",
+		Filename: "default.nix",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.4.2", v, "error with getVersion for a .nix")
+
+	b, err := ioutil.ReadFile("test_data/next_version/nix/default.nix")
+	assert.NoError(t, err)
+
+	f := nixVersionFile{}
+	output, err := f.Write(b, "1.5.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `version = "1.4.2";`, `version = "1.5.0";`, 1)
+	assert.Equal(t, expected, string(output))
+
+	_, err = f.Read([]byte("{\n  version = \"${base}-rc\";\n}\n"))
+	assert.Equal(t, errNoVersion, err, "an interpolated version is not a literal")
+}
+
+func TestJuliaProject(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/julia",
+		Filename: "Project.toml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.3.1", v, "error with getVersion for a Project.toml")
+
+	b, err := ioutil.ReadFile("test_data/next_version/julia/Project.toml")
+	assert.NoError(t, err)
+
+	f := tomlVersionFile{}
+	output, err := f.Write(b, "0.4.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `version = "0.3.1"`, `version = "0.4.0"`, 1)
+	assert.Equal(t, expected, string(output))
+
+	_, err = f.Read([]byte("name = \"MyPackage\"\n\n[compat]\nversion = \"1.0.0\"\n"))
+	assert.Equal(t, errNoVersion, err, "only the top level version is the package version")
+}
+
+func TestCommitizen(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:        "test_data/next_version/commitizen",
+		Filename:   "pyproject.toml",
+		Commitizen: true,
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for the commitizen version of a pyproject.toml")
+
+	o = StepNextVersionOptions{
+		Dir:      "test_data/next_version/commitizen",
+		Filename: ".cz.toml",
+	}
+	v, err = o.getVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "0.7.0", v, "error with getVersion for a .cz.toml")
+
+	b, err := ioutil.ReadFile("test_data/next_version/commitizen/pyproject.toml")
+	assert.NoError(t, err)
+
+	f := tomlVersionFile{table: commitizenTable}
+	output, err := f.Write(b, "1.3.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `version = "1.2.3"`, `version = "1.3.0"`, 1)
+	assert.Equal(t, expected, string(output), "only the commitizen version should be updated")
+}
+
+func TestGradleVersionCatalog(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:        "test_data/next_version/catalog",
+		Filename:   "gradle/libs.versions.toml",
+		CatalogKey: "myapp",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a libs.versions.toml")
+
+	b, err := ioutil.ReadFile("test_data/next_version/catalog/gradle/libs.versions.toml")
+	assert.NoError(t, err)
+
+	f := tomlVersionFile{table: "versions", key: "ktor-server"}
+	output, err := f.Write(b, "2.3.8")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `ktor-server = "2.3.7"`, `ktor-server = "2.3.8"`, 1)
+	assert.Equal(t, expected, string(output))
+
+	o.CatalogKey = ""
+	err = o.Run()
+	assert.Error(t, err, "the catalog key is required")
+}
+
+func TestGradleSettings(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/gradle",
+		Filename: "settings.gradle",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a settings.gradle")
+
+	o = StepNextVersionOptions{
+		Dir:      "test_data/next_version/gradle-kts",
+		Filename: "settings.gradle.kts",
+	}
+	v, err = o.getVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.1", v, "error with getVersion for a settings.gradle.kts")
+
+	f := gradleSettingsVersionFile{}
+	for file, old := range map[string]string{"gradle/settings.gradle": "'1.2.3'", "gradle-kts/settings.gradle.kts": `"2.0.1"`} {
+		b, err := ioutil.ReadFile(filepath.Join("test_data/next_version", file))
+		assert.NoError(t, err)
+		output, err := f.Write(b, "3.0.0")
+		assert.NoError(t, err)
+		expected := strings.Replace(string(b), old, old[:1]+"3.0.0"+old[:1], 1)
+		assert.Equal(t, expected, string(output), "error writing the version of %s", file)
+	}
+
+	_, err = f.Read([]byte("rootProject.version = providers.gradleProperty(\"version\").get()\n"))
+	assert.Error(t, err)
+	assert.NotEqual(t, errNoVersion, err, "a version from a property should be reported")
+}
+
+func TestPerlModule(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/perl",
+		Filename: "lib/My/Module.pm",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.23", v, "error with getVersion for a .pm")
+
+	b, err := ioutil.ReadFile("test_data/next_version/perl/lib/My/Module.pm")
+	assert.NoError(t, err)
+
+	f := perlVersionFile{}
+	output, err := f.Write(b, "1.24")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "'1.23'", "'1.24'", 1)
+	assert.Equal(t, expected, string(output))
+
+	dotted := []byte("package My::Module;\nour $VERSION = v1.2.3;\n")
+	v, err = f.Read(dotted)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.2.3", v, "the raw version is returned")
+
+	output, err = f.Write(dotted, "1.2.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "package My::Module;\nour $VERSION = v1.2.4;\n", string(output), "a dotted version keeps its v")
+}
+
+func TestCabal(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/haskell",
+		Filename: "my-tool.cabal",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.2.1.0", v, "error with getVersion for a .cabal")
+
+	b, err := ioutil.ReadFile("test_data/next_version/haskell/my-tool.cabal")
+	assert.NoError(t, err)
+
+	f := cabalVersionFile{}
+	output, err := f.Write(b, "0.2.2")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "0.2.1.0\n", "0.2.2\n", 1)
+	assert.Equal(t, expected, string(output), "the cabal-version should not change")
+
+	v, err = f.Read([]byte("Name: my-lib\nVersion: 1.0\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0", v, "field names are case insensitive")
+}
+
+func TestYamlPath(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/yamlpath",
+		Filename: "config.yml",
+		YamlPath: "default.app.version",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.10", v, "error with getVersion for a yaml-path")
+
+	b, err := ioutil.ReadFile("test_data/next_version/yamlpath/config.yml")
+	assert.NoError(t, err)
+
+	f := yamlPathVersionFile{path: []string{"default", "app", "version"}}
+	output, err := f.Write(b, "1.11.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "version: 1.10", "version: 1.11.0", 1)
+	assert.Equal(t, expected, string(output), "only the app version should change")
+
+	f = yamlPathVersionFile{path: []string{"default", "app", "release"}}
+	_, err = f.Read(b)
+	assert.Error(t, err)
+}
+
+func TestJSONPath(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/jsonpath",
+		Filename: "values.schema.json",
+		JSONPath: "$.properties.image.properties.tag.default",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.7.2", v, "error with getVersion for a json-path")
+
+	b, err := ioutil.ReadFile("test_data/next_version/jsonpath/values.schema.json")
+	assert.NoError(t, err)
+
+	f := jsonPathVersionFile{path: []string{"properties", "image", "properties", "tag", "default"}}
+	output, err := f.Write(b, "0.8.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `"default": "0.7.2"`, `"default": "0.8.0"`, 1)
+	assert.Equal(t, expected, string(output), "only the tag default should change")
+
+	f = jsonPathVersionFile{path: []string{"properties", "image", "type", "default"}}
+	_, err = f.Read(b)
+	assert.Equal(t, errNoVersion, err)
+}
+
+func TestRegex(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/regex",
+		Filename: ".tool-versions",
+		Regex:    `(?m)^(my-tool) (\S+)$`,
+		Group:    2,
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.8.3", v, "error with getVersion for a regex")
+
+	b, err := ioutil.ReadFile("test_data/next_version/regex/.tool-versions")
+	assert.NoError(t, err)
+
+	f := regexVersionFile{regex: o.Regex, group: 2}
+	output, err := f.Write(b, "0.9.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "my-tool 0.8.3", "my-tool 0.9.0", 1)
+	assert.Equal(t, expected, string(output))
+
+	f.group = 3
+	_, err = f.Read(b)
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+
+	f = regexVersionFile{regex: `(?m)^terraform (\S+)$`, group: 1}
+	_, err = f.Read(b)
+	assert.Equal(t, errNoVersion, err)
+
+	f = regexVersionFile{regex: `(unclosed`, group: 1}
+	_, err = f.Read(b)
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+}
+
+func TestWriteRegex(t *testing.T) {
+	b := []byte("# My Tool\n\nInstall with `go get github.com/my-org/my-tool@v0.8.3` or use the 0.8 docker image\n")
+
+	f := regexVersionFile{
+		group:         1,
+		writeRegex:    `my-tool@(\S+)` + "`",
+		writeTemplate: "v{{.Version}}",
+	}
+	v, err := f.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "v0.8.3", v)
+
+	output, err := f.Write(b, "0.9.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "my-tool@v0.8.3", "my-tool@v0.9.0", 1)
+	assert.Equal(t, expected, string(output))
+
+	f.writeRegex = `the (\S+) docker image`
+	f.writeTemplate = "{{.Major}}.{{.Minor}}"
+	output, err = f.Write(b, "0.9.0")
+	assert.NoError(t, err)
+	expected = strings.Replace(string(b), "the 0.8 docker", "the 0.9 docker", 1)
+	assert.Equal(t, expected, string(output))
+
+	f.writeTemplate = "{{.Unknown}}"
+	_, err = f.Write(b, "0.9.0")
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+}
+
+func TestWordPressPlugin(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/wordpress",
+		Filename: "my-plugin.php",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.4.0", v, "error with getVersion for a .php")
+
+	b, err := ioutil.ReadFile("test_data/next_version/wordpress/my-plugin.php")
+	assert.NoError(t, err)
+
+	f := phpHeaderVersionFile{}
+	output, err := f.Write(b, "1.5.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "Version:           1.4.0", "Version:           1.5.0", 1)
+	assert.Equal(t, expected, string(output), "only the version in the plugin header should change")
+
+	_, err = f.Read([]byte("<?php\n$x = 1;\n/*\n * Version: 1.0.0\n */\n"))
+	assert.Equal(t, errNoVersion, err, "the header comment must be at the top of the file")
+}
+
+func TestPubspec(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/flutter",
+		Filename: "pubspec.yaml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3+45", v, "error with getVersion for a pubspec.yaml")
+
+	b, err := ioutil.ReadFile("test_data/next_version/flutter/pubspec.yaml")
+	assert.NoError(t, err)
+
+	f := pubspecVersionFile{}
+	output, err := f.Write(b, "1.2.4")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "version: 1.2.3+45", "version: 1.2.4+45", 1)
+	assert.Equal(t, expected, string(output), "the build number is kept")
+
+	f = pubspecVersionFile{incrementBuildNumber: true}
+	output, err = f.Write(b, "1.2.3")
+	assert.NoError(t, err)
+	expected = strings.Replace(string(b), "version: 1.2.3+45", "version: 1.2.3+46", 1)
+	assert.Equal(t, expected, string(output), "the build number is incremented even if the version doesn't change")
+
+	output, err = f.Write([]byte("name: my_app\nversion: 0.1.0\n"), "0.1.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "name: my_app\nversion: 0.1.1+1\n", string(output))
+}
+
+func TestReleasePleaseManifest(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:          "test_data/next_version/release-please",
+		Filename:     ".release-please-manifest.json",
+		ManifestPath: "packages/api",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.4.2", v, "error with getVersion for a .release-please-manifest.json")
+
+	b, err := ioutil.ReadFile("test_data/next_version/release-please/.release-please-manifest.json")
+	assert.NoError(t, err)
+
+	f := releasePleaseVersionFile{path: "."}
+	v, err = f.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.3.0", v)
+
+	output, err := f.Write(b, "2.4.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `".": "2.3.0"`, `".": "2.4.0"`, 1)
+	assert.Equal(t, expected, string(output))
+
+	f = releasePleaseVersionFile{path: "packages/cli"}
+	_, err = f.Read(b)
+	assert.Equal(t, errNoVersion, err)
+}
+
+func TestAmperModule(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/amper",
+		Filename: "module.yaml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.4.1", v, "error with getVersion for an Amper module.yaml")
+
+	b, err := ioutil.ReadFile("test_data/next_version/amper/module.yaml")
+	assert.NoError(t, err)
+
+	f := o.versionFileFor("module.yaml")
+	output, err := f.Write(b, "0.5.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "version: 0.4.1", "version: 0.5.0", 1)
+	assert.Equal(t, expected, string(output))
+
+	_, err = f.Read([]byte("product: jvm/app\n"))
+	assert.Error(t, err)
+}
+
+func TestGemfileLock(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/gemlock",
+		Filename: "Gemfile.lock",
+		LockGem:  "my_gem",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a Gemfile.lock")
+
+	b, err := ioutil.ReadFile("test_data/next_version/gemlock/Gemfile.lock")
+	assert.NoError(t, err)
+
+	f := gemfileLockVersionFile{gem: "my_gem"}
+	output, err := f.Write(b, "1.3.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "    my_gem (1.2.3)", "    my_gem (1.3.0)", 1)
+	assert.Equal(t, expected, string(output), "only the spec of the gem should change")
+
+	f = gemfileLockVersionFile{gem: "activesupport"}
+	_, err = f.Read(b)
+	assert.Equal(t, errNoVersion, err, "only the gems in the PATH section are the repository's")
+
+	f = gemfileLockVersionFile{}
+	_, err = f.Read(b)
+	assert.Error(t, err, "the gem must be chosen when there is more than one")
+
+	v, err = f.Read([]byte("PATH\n  remote: .\n  specs:\n    solo (0.1.0)\n\nGEM\n  specs:\n    rake (12.3.1)\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1.0", v)
+}
+
+func TestDelphiProject(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/delphi",
+		Filename: "MyApp.dproj",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.4.1.17", v, "error with getVersion for a .dproj")
+
+	b, err := ioutil.ReadFile("test_data/next_version/delphi/MyApp.dproj")
+	assert.NoError(t, err)
+
+	f := dprojVersionFile{}
+	output, err := f.Write(b, "2.5.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "<VerInfo_MinorVer>4<", "<VerInfo_MinorVer>5<", -1)
+	expected = strings.Replace(expected, "<VerInfo_Release>1<", "<VerInfo_Release>0<", -1)
+	expected = strings.Replace(expected, "<VerInfo_Build>17<", "<VerInfo_Build>0<", -1)
+	expected = strings.Replace(expected, "FileVersion=2.4.1.17", "FileVersion=2.5.0.0", -1)
+	expected = strings.Replace(expected, "ProductVersion=2.4.1.0", "ProductVersion=2.5.0.0", -1)
+	assert.Equal(t, expected, string(output), "every build configuration should be updated")
+
+	output, err = f.Write(b, "2.4.1.18")
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "<VerInfo_Build>18</VerInfo_Build>")
+	assert.Contains(t, string(output), "FileVersion=2.4.1.18;")
+
+	_, err = f.Write(b, "2.5.0-rc.1")
+	assert.Error(t, err)
+
+	keysOnly := []byte("<VerInfo_Keys>CompanyName=;FileVersion=1.0.3.2;ProductVersion=1.0.0.0</VerInfo_Keys>")
+	v, err = f.Read(keysOnly)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.3.2", v)
+}
+
+func TestDirectoryBuildProps(t *testing.T) {
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/dotnet",
+		Filename: "Directory.Build.props",
+	}
+	v, err := o.getVersion()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a Directory.Build.props")
+
+	b, err := ioutil.ReadFile("test_data/next_version/dotnet/Directory.Build.props")
+	assert.NoError(t, err)
+
+	f := msbuildPropsVersionFile{}
+	output, err := f.Write(b, "1.3.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "<Version>1.2.3<", "<Version>1.3.0<", 1)
+	assert.Equal(t, expected, string(output), "only the Version property should be updated")
+
+	_, err = f.Read([]byte("<Project><PropertyGroup><Version>$(VersionPrefix)</Version></PropertyGroup></Project>"))
+	assert.Error(t, err)
+}
+
+func TestVcpkgJSON(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/cpp",
+		Filename: "vcpkg.json",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.3.0", v, "error with getVersion for a vcpkg.json")
+
+	b, err := ioutil.ReadFile("test_data/next_version/cpp/vcpkg.json")
+	assert.NoError(t, err)
+
+	f := vcpkgVersionFile{}
+	output, err := f.Write(b, "1.4.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), `"version-semver": "1.3.0"`, `"version-semver": "1.4.0"`, 1), string(output))
+}
+
+func TestConanfile(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/cpp",
+		Filename: "conanfile.py",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.3.0", v, "error with getVersion for a conanfile.py")
+
+	b, err := ioutil.ReadFile("test_data/next_version/cpp/conanfile.py")
+	assert.NoError(t, err)
+
+	f := conanVersionFile{}
+	output, err := f.Write(b, "1.4.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), `version = "1.3.0"`, `version = "1.4.0"`, 1), string(output))
+}
+
+func TestCMakeLists(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/cmake",
+		Filename: "CMakeLists.txt",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.4.1", v, "error with getVersion for a CMakeLists.txt")
+
+	b, err := ioutil.ReadFile("test_data/next_version/cmake/CMakeLists.txt")
+	assert.NoError(t, err)
+
+	f := cmakeVersionFile{}
+	output, err := f.Write(b, "2.5.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), "version 2.4.1", "version 2.5.0", 1), string(output))
+
+	_, err = f.Read([]byte("cmake_minimum_required(VERSION 3.14)\nproject(my_app LANGUAGES C)\n"))
+
+	assert.Error(t, err)
+}
+
+func TestModuleBazel(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/bazel",
+		Filename: "MODULE.bazel",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.6.0", v, "error with getVersion for a MODULE.bazel")
+
+	b, err := ioutil.ReadFile("test_data/next_version/bazel/MODULE.bazel")
+	assert.NoError(t, err)
+
+	f := bazelModuleVersionFile{}
+	output, err := f.Write(b, "0.7.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), `version = "0.6.0"`, `version = "0.7.0"`, 1), string(output))
+}
+
+func TestOpenAPI(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/openapi",
+		Filename: "openapi.yaml",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.0.7", v, "error with getVersion for an openapi.yaml")
+
+	o.Filename = "swagger.json"
+	v, err = o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.0.7", v, "error with getVersion for a swagger.json")
+
+	b, err := ioutil.ReadFile("test_data/next_version/openapi/openapi.yaml")
+	assert.NoError(t, err)
+
+	f := openAPIVersionFile{}
+	output, err := f.Write(b, "1.1.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), "version: '1.0.7'", "version: '1.1.0'", 1), string(output))
+
+	b, err = ioutil.ReadFile("test_data/next_version/openapi/swagger.json")
+	assert.NoError(t, err)
+
+	f = openAPIVersionFile{json: true}
+	output, err = f.Write(b, "1.1.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), `"version": "1.0.7"`, `"version": "1.1.0"`, 1), string(output))
+}
+
+func TestSetYamlPathString(t *testing.T) {
+	b := []byte("a:\n  b:\n    c: 1 # the c\n  c: 2\nc: 3\n")
+
+	output, err := setYamlPathString(b, []string{"a", "b", "c"}, "9")
+	assert.NoError(t, err)
+	assert.Equal(t, "a:\n  b:\n    c: 9 # the c\n  c: 2\nc: 3\n", string(output))
+
+	output, err = setYamlPathString(b, []string{"c"}, "9")
+	assert.NoError(t, err)
+	assert.Equal(t, "a:\n  b:\n    c: 1 # the c\n  c: 2\nc: 9\n", string(output))
+
+	_, err = setYamlPathString(b, []string{"a", "d"}, "9")
+	assert.Error(t, err)
+
+	_, err = setYamlPathString(b, []string{"a", "b"}, "9")
+	assert.Error(t, err, "a mapping is not a scalar")
+}
+
+func TestDotVersionFallbackToTag(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/dotversion",
+		Filename: ".version",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "3.1.4", v, "error with getVersion for a .version")
+
+	o.Dir = "test_data/next_version/dotversion_empty"
+	_, err = o.getVersion()
+
+	assert.Error(t, err)
+
+	o.FallbackToTag = true
+	v, err = o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", v, "an empty file should fall back to the git tags")
+
+	o.Dir = "test_data/next_version/does_not_exist"
+	v, err = o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", v, "a missing file should fall back to the git tags")
+
+	err = o.setVersion()
+
+	assert.NoError(t, err, "there is nothing to update when the file is missing")
+}
+
+func TestSetRubySpecVersion(t *testing.T) {
+
+	b, literal := setRubySpecVersion([]byte("  spec.version       = '0.3.2'.freeze\n"), "1.2.3")
+	assert.True(t, literal)
+	assert.Equal(t, "  spec.version       = '1.2.3'.freeze\n", string(b))
+
+	_, literal = setRubySpecVersion([]byte("  s.version = MyPod::VERSION\n"), "1.2.3")
+	assert.False(t, literal)
+}
+
+func TestAddChangelogHeading(t *testing.T) {
+
+	changelog := `# Changelog
+
+## [Unreleased]
+### Added
+- something new
+
+## [1.2.2] - 2018-06-01
+### Fixed
+- a bug
+`
+	expected := `# Changelog
+
+## [Unreleased]
+
+## [1.2.3] - 2018-07-01
+### Added
+- something new
+
+## [1.2.2] - 2018-06-01
+### Fixed
+- a bug
+`
+	assert.Equal(t, expected, addChangelogHeading(changelog, "1.2.3", "2018-07-01"))
+
+	assert.Equal(t, "# Changelog\n\n## [1.2.3] - 2018-07-01\n\n## [1.2.2] - 2018-06-01\n",
+		addChangelogHeading("# Changelog\n\n## [1.2.2] - 2018-06-01\n", "1.2.3", "2018-07-01"))
+}
+
+func TestNewVersionFromFile(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/make",
+		Filename: "Makefile",
+		Base:     "file",
+	}
+
+	v, previous, err := o.getNewVersionFromTag()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.1", v, "error incrementing the file version")
+	assert.Equal(t, "1.2.0-SNAPSHOT", previous, "error returning the previous file version")
+
+	for strategy, expected := range map[string]string{strategyMinor: "1.3.0", strategyMajor: "2.0.0"} {
+		o.Strategy = strategy
+		v, _, err = o.getNewVersionFromTag()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, v, "the %s strategy should increment the file version", strategy)
+	}
+
+	o.Strategy = "unknown"
+	_, _, err = o.getNewVersionFromTag()
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+}
+
+func TestToSemver(t *testing.T) {
+
+	testCases := map[string]string{
+		"1.0-SNAPSHOT": "1.0.0-SNAPSHOT",
+		"1.2.3.4":      "1.2.3+4",
+		"v1.2.3+meta":  "1.2.3+meta",
+		"1.2.3-rc.01":  "1.2.3-rc.1",
+		"1.2.3-rc~1":   "1.2.3-rc-1",
+		"2":            "2.0.0",
+	}
+	for input, expected := range testCases {
+		sv, err := toSemver(input)
+		assert.NoError(t, err, "error converting %s", input)
+		assert.Equal(t, expected, sv.String(), "error converting %s", input)
+	}
+
+	_, err := toSemver("not-a-version")
+	assert.Error(t, err)
+}
+
+func TestBumpversionCfg(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/bumpversion",
+		Filename: ".bumpversion.cfg",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.4.2", v, "error with getVersion for a .bumpversion.cfg")
+}
+
+func TestTerraform(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:          "test_data/next_version/terraform",
+		Filename:     "versions.tf",
+		HclAttribute: "module_version",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.7.1", v, "error with getVersion for a Terraform file")
+}
+
+func TestTerraformKeepsPins(t *testing.T) {
+	b, err := ioutil.ReadFile("test_data/next_version/terraform_pins/main.tf")
+	assert.NoError(t, err)
+
+	f := hclVersionFile{}
+	v, err := f.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.1.0", v)
+
+	output, err := f.Write(b, "2.2.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `version = "2.1.0"`, `version = "2.2.0"`, 1)
+	assert.Equal(t, expected, string(output), "the provider and module versions should not be updated")
+
+	f = hclVersionFile{attribute: "module_version"}
+	_, err = f.Read(b)
+	assert.Equal(t, errNoVersion, err)
+}
+
+func TestElmJSON(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/elm",
+		Filename: "elm.json",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.0.4", v, "error with getVersion for an elm.json")
+}
+
+func TestDenoJSONC(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/deno",
+		Filename: "deno.jsonc",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "0.2.0", v, "error with getVersion for a deno.jsonc")
+
+	b, err := ioutil.ReadFile("test_data/next_version/deno/deno.jsonc")
+	assert.NoError(t, err)
+
+	f := denoVersionFile{}
+	output, err := f.Write(b, "0.3.0")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(b), `"version": "0.2.0"`, `"version": "0.3.0"`, 1), string(output), "the comments should be kept")
+}
+
+func TestDenoJSONNestedVersion(t *testing.T) {
+	b, err := ioutil.ReadFile("test_data/next_version/deno_tasks/deno.json")
+	assert.NoError(t, err)
+
+	f := denoVersionFile{}
+	v, err := f.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.2.0", v)
+
+	output, err := f.Write(b, "0.3.0")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `"version": "0.2.0"`, `"version": "0.3.0"`, 1)
+	assert.Equal(t, expected, string(output), "only the top level version should be updated")
+}
+
+func TestSetJSONStringField(t *testing.T) {
+
+	b, err := setJSONStringField([]byte(`{
+    "elm-version": "0.19.0 <= v < 0.20.0",
+    "version": "1.0.4"
+}`), "version", "1.2.3")
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+    "elm-version": "0.19.0 <= v < 0.20.0",
+    "version": "1.2.3"
+}`, string(b))
+
+	b, err = setJSONStringField([]byte(`{
+  // the module version
+  "version": "0.2.0", /* "version": "9.9.9" */
+}`), "version", "1.2.3")
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  // the module version
+  "version": "1.2.3", /* "version": "9.9.9" */
+}`, string(b))
+
+	_, err = setJSONStringField([]byte(`{}`), "version", "1.2.3")
+	assert.Error(t, err)
+}
+
+type testVersionFile struct{}
+
+func (f *testVersionFile) Read(b []byte) (string, error) {
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (f *testVersionFile) Write(b []byte, newVersion string) ([]byte, error) {
+	return []byte(newVersion + "\n"), nil
+}
+
+func TestRegisterVersionFile(t *testing.T) {
+
+	RegisterVersionFile(".testversion", func(o *StepNextVersionOptions) VersionFile {
+		return &testVersionFile{}
+	})
+	defer delete(versionFileFactories, ".testversion")
+
+	f, err := ioutil.TempDir("", "test-version-file")
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(f, "app.testversion"), []byte("3.2.1\n"), 0644)
+	assert.NoError(t, err)
+
+	o := StepNextVersionOptions{
+		Dir:      f,
+		Filename: "app.testversion",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "3.2.1", v, "error with getVersion for a registered VersionFile")
+}
+
+func TestVersionProperties(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:         "test_data/next_version/properties",
+		Filename:    "version.properties",
+		PropertyKey: "app.version",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.3.4", v, "error with getVersion for a version.properties")
+
+	f := propertiesVersionFile{key: "app.version"}
+	b, err := f.Write([]byte("app.name=my-app\napp.version = 2.3.4\n"), "2.4.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "app.name=my-app\napp.version = 2.4.0\n", string(b))
+
+	_, err = f.Read([]byte("app.name=my-app\n"))
+	assert.Equal(t, errNoVersion, err)
+}
+
+func TestPkgbuild(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/arch",
+		Filename: "PKGBUILD",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.3.0", v, "error with getVersion for a PKGBUILD")
+
+	f := pkgbuildVersionFile{}
+	b, err := f.Write([]byte("pkgname=my-app\npkgver=1.3.0\npkgrel=4\n"), "1.4.0-rc.1")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "pkgname=my-app\npkgver=1.4.0_rc.1\npkgrel=1\n", string(b))
+}
+
+func TestRpmSpec(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/rpm",
+		Filename: "my-app.spec",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.0.1", v, "error with getVersion for an RPM spec")
+
+	f := rpmSpecVersionFile{resetRelease: true}
+	b, err := f.Write([]byte("Name:\t\tmy-app\nVersion:\t2.0.1\nRelease:\t3%{?dist}\n\n%description\nThe Version: is above\n"), "2.1.0")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Name:\t\tmy-app\nVersion:\t2.1.0\nRelease:\t1%{?dist}\n\n%description\nThe Version: is above\n", string(b))
+}
+
+func TestDebianChangelog(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/debian",
+		Filename: "debian/changelog",
+	}
+
+	v, err := o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.4.2-1", v, "error with getVersion for a debian/changelog")
+
+	b, err := ioutil.ReadFile("test_data/next_version/debian/debian/changelog")
+	assert.NoError(t, err)
+
+	f := debianChangelogVersionFile{
+		now: func() time.Time {
+			return time.Date(2018, time.March, 6, 12, 30, 0, 0, time.UTC)
+		},
+	}
+	output, err := f.Write(b, "1.5.0")
+
+	assert.NoError(t, err)
+
+	expected := "my-app (1.5.0-1) unstable; urgency=medium\n\n  * Release 1.5.0-1\n\n -- Jane Doe <jane@example.com>  Tue, 06 Mar 2018 12:30:00 +0000\n\n"
+	assert.Equal(t, expected+string(b), string(output))
+
+	v, err = f.Read(output)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.5.0-1", v)
+}
+
+func TestTagName(t *testing.T) {
+	now := time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC)
+
+	o := StepNextVersionOptions{NewVersion: "1.2.3"}
+	tag, err := o.tagName(now)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.2.3", tag)
+
+	o.TagFormat = "release/{{.Year}}/v{{.Version}}"
+	tag, err = o.tagName(now)
+	assert.NoError(t, err)
+	assert.Equal(t, "release/2024/v1.2.3", tag)
+
+	o.TagFormat = "{{.Major}}.{{.Minor}}.x/{{.Patch}}-{{.Year}}{{.Month}}{{.Day}}"
+	tag, err = o.tagName(now)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.x/3-20240307", tag)
+
+	o.TagFormat = ""
+	o.NoVTag = true
+	tag, err = o.tagName(now)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", tag)
+
+	o.TagFormat = "v{{.Unknown}}"
+	_, err = o.tagName(now)
+	assert.Error(t, err)
+
+	o.TagFormat = "v{{.Version"
+	_, err = o.tagName(now)
+	assert.Error(t, err)
+}
+
+func TestTagVersionParser(t *testing.T) {
+	o := StepNextVersionOptions{}
+	tagVersion, err := o.tagVersionParser()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", tagVersion("v1.2.3"))
+	assert.Equal(t, "1.2.3", tagVersion("1.2.3"))
+
+	o.TagPrefix = "api/v"
+	tagVersion, err = o.tagVersionParser()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", tagVersion("api/v1.2.3"))
+	assert.Equal(t, "", tagVersion("web/v1.2.3"), "the tag of another component")
+
+	o.TagFormat = "release/{{.Year}}/v{{.Version}}"
+	tagVersion, err = o.tagVersionParser()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3-rc.1", tagVersion("release/2024/v1.2.3-rc.1"))
+	assert.Equal(t, "", tagVersion("v1.2.3"))
+
+	o.TagFormat = "{{.Major}}.{{.Minor}}.x/{{.Patch}}-{{.Year}}{{.Month}}{{.Day}}"
+	tagVersion, err = o.tagVersionParser()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", tagVersion("1.2.x/3-20240307"))
+
+	o.TagFormat = "v{{.Version"
+	_, err = o.tagVersionParser()
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+}
+
+func TestRunTagFormatTwice(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-tag-format")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "first"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(f))
+	defer os.Chdir(wd)
+
+	for _, expected := range []string{"0.0.1", "0.0.2"} {
+		assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "fix: a change"))
+
+		o := StepNextVersionOptions{}
+		o.Out = tests.Output()
+		o.Dir = f
+		o.UseGitTagOnly = true
+		o.NoVersionFile = true
+		o.Tag = true
+		o.NoPush = true
+		o.TagFormat = "release-{{.Version}}"
+		err = o.Run()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, o.NewVersion, "the version should be worked out from the tags of the tag format")
+
+		tagged, err := o.tagExists("release-" + expected)
+		assert.NoError(t, err)
+		assert.True(t, tagged)
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "test-env-file")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	err = writeEnvFile(f.Name(), "1.2.4", "1.2.3", "release/v1.2.4", nil)
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "VERSION=1.2.4\nPREVIOUS_VERSION=1.2.3\nTAG=release/v1.2.4\n", string(b))
+
+	assert.Equal(t, "", shellQuote(""))
+	assert.Equal(t, `'it'\''s $HOME'`, shellQuote("it's $HOME"))
+
+	components, err := parseVersionComponents("1.2.4-rc.1")
+	assert.NoError(t, err)
+	assert.Equal(t, VersionComponents{Major: 1, Minor: 2, Patch: 4, Prerelease: "rc.1"}, *components)
+
+	err = writeEnvFile(f.Name(), "1.2.4-rc.1", "1.2.3", "v1.2.4-rc.1", components)
+	assert.NoError(t, err)
+
+	b, err = ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "VERSION=1.2.4-rc.1\nPREVIOUS_VERSION=1.2.3\nTAG=v1.2.4-rc.1\nMAJOR=1\nMINOR=2\nPATCH=4\nPRERELEASE=rc.1\n", string(b))
+}
+
+func TestPrintOnly(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-print-only")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = util.CopyDir(path.Join("test_data", "next_version", "javascript"), f, true)
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	var out bytes.Buffer
+	o := StepNextVersionOptions{}
+	o.Out = &out
+	o.Dir = f
+	o.Filename = "package.json"
+	o.NewVersion = "1.2.3"
+	o.PrintOnly = true
+	err = o.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3\n", out.String())
+
+	_, err = os.Stat(filepath.Join(f, "VERSION"))
+	assert.True(t, os.IsNotExist(err), "the VERSION file should not be written")
+
+	b, err := ioutil.ReadFile(filepath.Join(f, "package.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"version": "0.0.1"`)
+}
+
+func TestDryRun(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-dry-run")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = util.CopyDir(path.Join("test_data", "next_version", "javascript"), f, true)
+	assert.NoError(t, err)
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "add", "."))
+	assert.NoError(t, gits.GitCmd(f, "commit", "-m", "first"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	var out bytes.Buffer
+	o := StepNextVersionOptions{}
+	o.Out = &out
+	o.Dir = f
+	o.Filename = "package.json"
+	o.NewVersion = "1.2.3"
+	o.Tag = true
+	o.TagFormat = "release-{{.Version}}"
+	o.DryRun = true
+	err = o.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "version: 1.2.3\ntag: release-1.2.3\nmessage:\nrelease 1.2.3\n", out.String())
+
+	_, err = os.Stat(filepath.Join(f, "VERSION"))
+	assert.True(t, os.IsNotExist(err), "the VERSION file should not be written")
+
+	tags, err := o.getCommandOutput(f, "git", "tag")
+	assert.NoError(t, err)
+	assert.Empty(t, tags, "no tag should be created")
+}
+
+func TestPreTagHook(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-pre-tag-hook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "first"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+	defer restoreEnv("VERSION")()
+	os.Unsetenv("VERSION")
+
+	o := StepNextVersionOptions{
+		NewVersion: "1.2.3",
+		NoPush:     true,
+		PreTagHook: "echo $VERSION $TAG > hook.txt",
+	}
+	err = o.tagVersion("v1.2.3")
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(f, "hook.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3 v1.2.3\n", string(b))
+	_, ok := os.LookupEnv("VERSION")
+	assert.False(t, ok, "the hook environment should not leak into the process")
+
+	o.NewVersion = "1.2.4"
+	o.AllowRetag = true
+	o.PreTagHook = "exit 1"
+	err = o.tagVersion("v1.2.4")
+	assert.Error(t, err)
+	assert.Equal(t, errCodeHookFailed, errorCode(err))
+
+	out, err := o.getCommandOutput(f, "git", "tag", "--list", "v1.2.4")
+	assert.NoError(t, err)
+	assert.Empty(t, out, "a failing pre tag hook should abort tagging")
+}
+
+func TestPostTagHook(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-post-tag-hook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "first"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		NewVersion:  "1.2.3",
+		NoPush:      true,
+		PostTagHook: "echo $VERSION $TAG > hook.txt",
+	}
+	err = o.tagVersion("v1.2.3")
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(f, "hook.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3 v1.2.3\n", string(b))
+
+	o.NewVersion = "1.2.4"
+	o.AllowRetag = true
+	o.PostTagHook = "exit 1"
+	err = o.tagVersion("v1.2.4")
+	assert.Error(t, err)
+	assert.Equal(t, errCodeHookFailed, errorCode(err))
+
+	out, err := o.getCommandOutput(f, "git", "tag", "--list", "v1.2.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.2.4", out, "the tag should be kept")
+
+	o.NewVersion = "1.2.5"
+	o.UndoTagOnPostHookFailure = true
+	err = o.tagVersion("v1.2.5")
+	assert.Error(t, err)
+
+	out, err = o.getCommandOutput(f, "git", "tag", "--list", "v1.2.5")
+	assert.NoError(t, err)
+	assert.Empty(t, out, "the tag should be deleted")
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	oldText := "a\nb\nc\nd\nversion: 1.0.0\ne\nf\ng\nh\ni\nj\nk\nl\nversion: 1.0.0\n"
+	newText := strings.Replace(oldText, "1.0.0", "1.1.0", -1)
+
+	expected := `--- a/Chart.yaml
++++ b/Chart.yaml
+@@ -2,7 +2,7 @@
+ b
+ c
+ d
+-version: 1.0.0
++version: 1.1.0
+ e
+ f
+ g
+@@ -11,4 +11,4 @@
+ j
+ k
+ l
+-version: 1.0.0
++version: 1.1.0
+`
+	assert.Equal(t, expected, unifiedDiff("Chart.yaml", oldText, newText))
+}
+
+func TestIncrementBuildSegment(t *testing.T) {
+
+	v, err := incrementBuildSegment("1.2.3.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.1", v, "error incrementing build segment")
+
+	v, err = incrementBuildSegment("1.2.3.9")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.10", v, "error incrementing build segment")
+
+	_, err = incrementBuildSegment("1.2.3")
+	assert.Error(t, err)
+}
+
+func TestNextVersionFromTag(t *testing.T) {
+	tag := semver.MustParse("1.4.3")
+
+	testCases := []struct {
+		name     string
+		base     string
+		expected string
+	}{
+		{"no file version", "", "1.4.4"},
+		{"equal", "1.4.3", "1.4.4"},
+		{"tag ahead", "1.4.1", "1.4.4"},
+		{"file ahead by one patch", "1.4.4", "1.4.4"},
+		{"file ahead", "1.4.6", "1.4.6"},
+		{"file minor ahead", "1.5.0", "1.5.0"},
+		{"file major ahead", "2.0.0", "2.0.0"},
+		{"file major behind but minor ahead", "0.5.9", "1.4.4"},
+		{"file major behind", "0.9.9", "1.4.4"},
+		{"file prerelease of the next patch", "1.4.4-rc.1", "1.4.4"},
+		{"file prerelease of the next minor", "1.5.0-rc.1", "1.5.0"},
+	}
+	for _, test := range testCases {
+		var base *semver.Version
+		if test.base != "" {
+			v := semver.MustParse(test.base)
+			base = &v
+		}
+		assert.Equal(t, test.expected, nextVersionFromTag(tag, base).String(), test.name)
+	}
+}
+
+func TestNextVersionFromTagComparison(t *testing.T) {
+	testCases := []struct {
+		base     string
+		tag      string
+		expected string
+	}{
+		{"2.0.0", "1.9.9", "2.0.0"},
+		{"1.5.0", "1.4.9", "1.5.0"},
+		{"1.4.8", "1.4.9", "1.4.10"},
+	}
+	for _, test := range testCases {
+		base := semver.MustParse(test.base)
+		assert.Equal(t, test.expected, nextVersionFromTag(semver.MustParse(test.tag), &base).String(), "base %s tag %s", test.base, test.tag)
+	}
+}
+
+func TestHeadHasTag(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-head-has-tag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "tag", "v1.4.3")
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{}
+	tagged, err := o.headHasTag("v1.4.3")
+	assert.NoError(t, err)
+	assert.True(t, tagged)
+
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "second")
+	assert.NoError(t, err)
+
+	tagged, err = o.headHasTag("v1.4.3")
+	assert.NoError(t, err)
+	assert.False(t, tagged)
+}
+
+func TestSinceTag(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-since-tag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "not-a-version", "v2.0.0"} {
+		err = gits.GitCmd(f, "commit", "--allow-empty", "-m", tag)
+		assert.NoError(t, err)
+		err = gits.GitCmd(f, "tag", tag)
+		assert.NoError(t, err)
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		UseGitTagOnly: true,
+		SinceTag:      "v1.1.0",
+	}
+	v, previous, err := o.getNewVersionFromTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1.1", v)
+	assert.Equal(t, "1.1.0", previous)
+
+	o.SinceTag = "v1.2.0"
+	_, _, err = o.getNewVersionFromTag()
+	assert.Error(t, err, "the tag does not exist")
+
+	o.SinceTag = "not-a-version"
+	_, _, err = o.getNewVersionFromTag()
+	assert.Error(t, err, "the tag is not a version")
+}
+
+func TestQuiet(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-quiet")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(f, "Makefile"), []byte("VERSION := 1.0.0\n"), 0644)
+	assert.NoError(t, err)
+	err = gits.GitAdd(f, "Makefile")
+	assert.NoError(t, err)
+	err = gits.GitCommitDir(f, "first")
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	var out bytes.Buffer
+	o := StepNextVersionOptions{}
+	o.Out = &out
+	o.Dir = f
+	o.Filename = "Makefile"
+	o.NewVersion = "1.0.1"
+	o.NoVersionFile = true
+	o.Tag = true
+	o.NoPush = true
+	o.Quiet = true
+	err = o.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.1\n", out.String())
+
+	tagged, err := o.headHasTag("v1.0.1")
+	assert.NoError(t, err)
+	assert.True(t, tagged)
+}
+
+func TestVersionFileNotFound(t *testing.T) {
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/does_not_exist",
+		Filename: "package.json",
+	}
+	_, err := o.getVersion()
+	assert.Equal(t, errCodeMissingFile, errorCode(err))
+	assert.EqualError(t, err, "version file package.json not found in test_data/next_version/does_not_exist, to version from the git tags alone use the flag use-git-tag-only or fallback-to-tag")
+
+	o.NewVersion = "1.0.0"
+	err = o.setVersion()
+	assert.Equal(t, errCodeMissingFile, errorCode(err))
+}
+
+func TestOutputJSONError(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-output-json")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	var out bytes.Buffer
+	o := StepNextVersionOptions{}
+	o.Out = &out
+	o.Output = "json"
+	o.UseGitTagOnly = true
+	err = o.Run()
+	assert.Error(t, err)
+	assert.Equal(t, errCodeNoCommits, errorCode(err))
+	assert.Equal(t, `{"error":"no commits to tag, please commit to the repository before running this step","code":"no_commits"}`+"\n", out.String())
+
+	out.Reset()
+	o.Output = "xml"
+	err = o.Run()
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+	assert.Equal(t, "", out.String(), "only json errors are printed")
+
+	o.Output = ""
+	o.Dir = "test_data/next_version/does_not_exist"
+	o.Filename = "package.json"
+	o.UseGitTagOnly = false
+	_, err = o.getVersion()
+	assert.Equal(t, errCodeMissingFile, errorCode(err))
+}
+
+func TestAllowRetag(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-allow-retag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+	for _, tag := range []string{"v1.0.0", "deployed", "web/v3.0.0"} {
+		err = gits.GitCmd(f, "tag", tag)
+		assert.NoError(t, err)
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		NewVersion: "1.0.1",
+		NoPush:     true,
+	}
+	err = o.tagVersion("v1.0.1")
+	assert.Error(t, err)
+	assert.Equal(t, errCodeHeadTagged, errorCode(err))
+
+	o.TagPrefix = "api/v"
+	err = o.tagVersion("api/v1.0.1")
+	assert.NoError(t, err, "the tags of other components don't count")
+
+	o.TagPrefix = ""
+	o.AllowRetag = true
+	err = o.tagVersion("v1.0.1")
+	assert.NoError(t, err)
+
+	tagged, err := o.headHasTag("v1.0.1")
+	assert.NoError(t, err)
+	assert.True(t, tagged)
+}
+
+func TestPreviewVersion(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-preview-version")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		PullRequest: "PR-23",
+	}
+	sha, err := o.getCommandOutput(f, "git", "rev-parse", "HEAD")
+	assert.NoError(t, err)
+
+	v, err := o.previewVersion("1.2.4")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(v, "1.2.4-pr.23."), "unexpected preview version %s", v)
+	assert.True(t, strings.HasPrefix(sha, strings.TrimPrefix(v, "1.2.4-pr.23.")), "preview version %s should end with the short sha", v)
+
+	o.PullRequest = "master"
+	_, err = o.previewVersion("1.2.4")
+	assert.Error(t, err)
+
+	o.Preview = true
+	o.Tag = true
+	err = o.Run()
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err), "a preview cannot be tagged")
+}
+
+func TestTagsFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "test-tags-file")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	err = ioutil.WriteFile(f.Name(), []byte("v1.2.0\nv1.10.0\n\n  v1.9.3  \nnightly\n"), 0644)
+	assert.NoError(t, err)
+
+	o := StepNextVersionOptions{
+		TagsFile: f.Name(),
+	}
+	tag, err := o.getLatestTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.10.0", tag)
+
+	err = ioutil.WriteFile(f.Name(), []byte("nightly\n"), 0644)
+	assert.NoError(t, err)
+	tag, err = o.getLatestTag()
+	assert.Equal(t, errCodeNoTags, errorCode(err))
+	assert.Equal(t, "0.0.0", tag)
+
+	o.TagsFile = f.Name() + "-missing"
+	_, err = o.getLatestTag()
+	assert.Error(t, err)
+}
+
+func TestTagAnnotationFromChangelog(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-tag-annotation")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "tag", "v1.0.0")
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "fix the login page\n\nthe details")
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "Release 1.0.1")
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		Dir:                        f,
+		NewVersion:                 "1.0.1",
+		NoPush:                     true,
+		TagAnnotationFromChangelog: true,
+	}
+	message, err := o.tagAnnotation()
+	assert.NoError(t, err)
+	assert.Equal(t, "Release 1.0.1\n\n* fix the login page", message, "without a changelog the commits are used")
+
+	changelog := "# Changelog\n\n## [Unreleased]\n\n## [1.0.1] - 2018-10-01\n### Fixed\n- the login page\n\n## [1.0.0] - 2018-09-01\n### Added\n- everything\n"
+	err = ioutil.WriteFile(filepath.Join(f, changelogmd), []byte(changelog), 0644)
+	assert.NoError(t, err)
+	message, err = o.tagAnnotation()
+	assert.NoError(t, err)
+	assert.Equal(t, "Release 1.0.1\n\n### Fixed\n- the login page", message)
+
+	err = o.tagVersion("v1.0.1")
+	assert.NoError(t, err)
+	annotation, err := o.getCommandOutput(f, "git", "tag", "-l", "--format=%(contents)", "v1.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, message, annotation)
+}
+
+func TestIncrementPrereleaseOnly(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-increment-prerelease-only")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+	for _, tag := range []string{"v1.2.9", "v1.3.0-nightly.9", "v1.3.0-nightly.10", "v1.3.0-beta.12"} {
+		err = gits.GitCmd(f, "tag", tag)
+		assert.NoError(t, err)
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		UseGitTagOnly: true,
+		Channel:       "nightly",
+	}
+	v, previous, err := o.prereleaseOnlyVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.0-nightly.11", v, "only the nightly number is incremented")
+	assert.Equal(t, "1.3.0-nightly.10", previous)
+
+	o.UseGitTagOnly = false
+	o.Dir = filepath.Join(wd, "test_data", "next_version", "make")
+	o.Filename = "Makefile"
+	v, _, err = o.prereleaseOnlyVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0-nightly.1", v, "the version of the file is kept")
+
+	o = StepNextVersionOptions{
+		UseGitTagOnly: true,
+		Channel:       "alpha",
+	}
+	v, _, err = o.prereleaseOnlyVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.10-alpha.1", v, "without a file or channel tag the next version is worked out as usual")
+
+	o.Channel = ""
+	o.IncrementPrereleaseOnly = true
+	err = o.Run()
+	assert.Equal(t, errCodeInvalidFlag, errorCode(err))
+}
+
+func TestNoTagOnNoChange(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-no-tag-on-no-change")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{
+		NewVersion:      "1.0.0",
+		NoTagOnNoChange: true,
+	}
+	unchanged, err := o.versionUnchanged()
+	assert.NoError(t, err)
+	assert.False(t, unchanged, "there are no tags yet")
+
+	err = gits.GitCmd(f, "tag", "v1.0.0")
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "second")
+	assert.NoError(t, err)
+
+	unchanged, err = o.versionUnchanged()
+	assert.NoError(t, err)
+	assert.True(t, unchanged)
+
+	// there is no remote to push to so tagging would fail
+	o.Tag = true
+	o.NoVersionFile = true
+	err = o.Run()
+	assert.NoError(t, err)
+
+	o.NewVersion = "1.0.1"
+	unchanged, err = o.versionUnchanged()
+	assert.NoError(t, err)
+	assert.False(t, unchanged)
+}
+
+func TestVerifyClean(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-verify-clean")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(f, "Makefile"), []byte("VERSION := 1.0.0\n"), 0644)
+	assert.NoError(t, err)
+	err = gits.GitAdd(f, "Makefile")
+	assert.NoError(t, err)
+	err = gits.GitCommitDir(f, "first")
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{}
+	err = ioutil.WriteFile(filepath.Join(f, "VERSION"), []byte("1.0.1"), 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, o.verifyClean(), "the VERSION file written by the step should be ignored")
+
+	err = ioutil.WriteFile(filepath.Join(f, "Makefile"), []byte("VERSION := 1.0.1\n"), 0644)
+	assert.NoError(t, err)
+	err = o.verifyClean()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Makefile")
+}
+
+func TestChannelVersion(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-channel-version")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+	for _, tag := range []string{"v1.2.3", "v1.2.4-beta.1", "v1.2.4-beta.2", "v1.2.4-beta.10", "v1.2.4-next.5"} {
+		err = gits.GitCmd(f, "tag", tag)
+		assert.NoError(t, err)
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{Channel: "beta"}
+	v, err := o.channelVersion("1.2.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.4-beta.11", v)
+
+	o.Channel = "alpha"
+	v, err = o.channelVersion("1.2.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.4-alpha.1", v)
+
+	assert.False(t, (&StepNextVersionOptions{Channel: "latest"}).isPrereleaseChannel())
+}
+
+func TestRunStableAfterBeta(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-stable-after-beta")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "first"))
+	assert.NoError(t, gits.GitCmd(f, "tag", "v1.2.3"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(f))
+	defer os.Chdir(wd)
+
+	for _, channel := range []string{"beta", ""} {
+		assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "fix: a change"))
+
+		o := StepNextVersionOptions{}
+		o.Out = tests.Output()
+		o.Dir = f
+		o.UseGitTagOnly = true
+		o.NoVersionFile = true
+		o.Tag = true
+		o.NoPush = true
+		o.Channel = channel
+		err = o.Run()
+		assert.NoError(t, err)
+		if channel == "" {
+			assert.Equal(t, "1.2.4", o.NewVersion, "the beta prerelease should not affect the stable version")
+		} else {
+			assert.Equal(t, "1.2.4-beta.1", o.NewVersion)
+		}
+	}
+}
+
+func TestChannelVersionSeparator(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-channel-version-separator")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+	err = gits.GitCmd(f, "commit", "--allow-empty", "-m", "first")
+	assert.NoError(t, err)
+	for _, tag := range []string{"v1.2.3", "v1.2.4-rc.7", "v1.2.4-rc1", "v1.2.4-rc2"} {
+		err = gits.GitCmd(f, "tag", tag)
+		assert.NoError(t, err)
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{Channel: "rc", PrereleaseSeparator: "none"}
+	v, err := o.channelVersion("1.2.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.4-rc3", v)
+
+	latest, err := o.latestChannelVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.4-rc2", latest.String())
+
+	o.PrereleaseSeparator = "."
+	v, err = o.channelVersion("1.2.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.4-rc.8", v)
+}
+
+// restoreEnv returns a function which restores the environment variable to its current value, unsetting it if it
+// is not set so that later commands don't see an empty value
+func restoreEnv(key string) func() {
+	value, ok := os.LookupEnv(key)
+	return func() {
+		if ok {
+			os.Setenv(key, value)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestBuildMetadataVersion(t *testing.T) {
+	defer restoreEnv("BUILD_NUMBER")()
+	os.Setenv("BUILD_NUMBER", "5")
+
+	o := StepNextVersionOptions{BuildMetadata: "build"}
+	assert.Equal(t, "1.2.4+build.5", o.buildMetadataVersion("1.2.4"))
+
+	o.MetadataSeparator = "none"
+	assert.Equal(t, "1.2.4+build5", o.buildMetadataVersion("1.2.4"))
+
+	os.Setenv("BUILD_NUMBER", "")
+	assert.Equal(t, "1.2.4+build", o.buildMetadataVersion("1.2.4"))
+
+	assert.False(t, isValidSeparator("_"))
+}
+
+func TestPrereleaseFromBranch(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-prerelease-from-branch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "first"))
+	assert.NoError(t, gits.GitCmd(f, "tag", "v1.3.0"))
+	assert.NoError(t, gits.GitCmd(f, "checkout", "-b", "Release/1.3"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	var out bytes.Buffer
+	o := StepNextVersionOptions{PrereleaseFromBranch: true}
+	o.Out = &out
+	o.UseGitTagOnly = true
+	o.PrintOnly = true
+	err = o.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.1-release-1-3.1\n", out.String())
+
+	assert.Equal(t, "feature-jx-123-fix", prereleaseIdentifier("feature/JX-123_fix"))
+	assert.Equal(t, "", prereleaseIdentifier("/"))
+}
+
+func TestGetLatestTagBareAndVTags(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-latest-tag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	remote := filepath.Join(f, "remote.git")
+	repo := filepath.Join(f, "repo")
+	assert.NoError(t, gits.GitCmd(f, "init", "--bare", remote))
+	assert.NoError(t, os.MkdirAll(repo, 0755))
+	assert.NoError(t, gits.GitInit(repo))
+	assert.NoError(t, gits.GitCmd(repo, "remote", "add", "origin", remote))
+	assert.NoError(t, gits.GitCmd(repo, "commit", "--allow-empty", "-m", "first"))
+	for _, tag := range []string{"v1.2.3", "1.2.5", "v1.2.4"} {
+		assert.NoError(t, gits.GitCmd(repo, "tag", tag))
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(repo))
+	defer os.Chdir(wd)
+
+	for _, noVTag := range []bool{false, true} {
+		o := StepNextVersionOptions{NoVTag: noVTag}
+		tag, err := o.getLatestTag()
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.5", tag)
+	}
+}
+
+func TestGetLatestTagByCreatorDate(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-tag-sort")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	// an empty GIT_COMMITTER_DATE would break the commits of later tests
+	defer restoreEnv("GIT_COMMITTER_DATE")()
+
+	assert.NoError(t, gits.GitInit(f))
+	// a hotfix of an older release is tagged after the latest release
+	for tag, date := range map[string]string{"v2.0.0": "2020-01-01T12:00:00Z", "v1.9.1": "2020-01-02T12:00:00Z", "deployed": "2020-01-03T12:00:00Z"} {
+		os.Setenv("GIT_COMMITTER_DATE", date)
+		assert.NoError(t, gits.GitCmd(f, "commit", "--allow-empty", "-m", "release "+tag))
+		assert.NoError(t, gits.GitCmd(f, "tag", tag))
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(f))
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{}
+	tag, err := o.getLatestTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", tag)
+
+	o.TagSort = tagSortCreatorDate
+	tag, err = o.getLatestTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.1", tag, "the newer deployed tag is not a version")
+}
+
+func TestAutoUnshallow(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-auto-unshallow")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	origin := filepath.Join(f, "origin")
+	repo := filepath.Join(f, "repo")
+	assert.NoError(t, os.MkdirAll(origin, 0755))
+	assert.NoError(t, gits.GitInit(origin))
+	for _, tag := range []string{"v1.2.3", "v1.2.4"} {
+		assert.NoError(t, gits.GitCmd(origin, "commit", "--allow-empty", "-m", "release "+tag))
+		assert.NoError(t, gits.GitCmd(origin, "tag", tag))
+	}
+	assert.NoError(t, gits.GitCmd(f, "clone", "--depth", "1", "file://"+origin, repo))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(repo))
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{}
+	assert.True(t, o.isShallowClone())
+
+	o.AutoUnshallow = true
+	tag, err := o.getLatestTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.4", tag)
+	assert.False(t, o.isShallowClone(), "the clone should have been unshallowed")
+}
+
+func TestValidateIncrement(t *testing.T) {
+
+	assert.NoError(t, validateIncrement("1.2.4", "1.2.3"))
+	assert.NoError(t, validateIncrement("2.0.0", "1.9.9"))
+	assert.NoError(t, validateIncrement("1.2.3", "1.2.3-SNAPSHOT"))
+	assert.Error(t, validateIncrement("1.2.3", "1.2.3"))
+	assert.Error(t, validateIncrement("1.2.2", "1.2.3"))
+}
+
+func TestApplyMinVersion(t *testing.T) {
+
+	v, err := applyMinVersion("0.0.1", "2.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", v)
+
+	v, err = applyMinVersion("2.0.1", "2.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.1", v)
+
+	v, err = applyMinVersion("1.9.0", "v2.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", v)
+
+	_, err = applyMinVersion("1.9.0", "two")
+	assert.Error(t, err)
+}
+
+func TestProvidedVersionPrefix(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-version-prefix")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	for _, provided := range []string{"v1.2.3", "1.2.3"} {
+		o := StepNextVersionOptions{}
+		o.Out = ioutil.Discard
+		o.NewVersion = provided
+		err = o.Run()
+		assert.NoError(t, err)
+
+		b, err := ioutil.ReadFile(filepath.Join(f, "VERSION"))
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.3", string(b), "the VERSION file for --version %s", provided)
+
+		tag, err := o.tagName(time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", tag, "the tag for --version %s", provided)
+	}
+
+	assert.Equal(t, "version", trimVersionPrefix("version"))
+}
+
+func TestValidateMaxVersion(t *testing.T) {
+
+	assert.NoError(t, validateMaxVersion("1.9.9", "2.0.0"))
+	assert.NoError(t, validateMaxVersion("2.0.0", "2.0.0"))
+	assert.Error(t, validateMaxVersion("9.0.0", "2.0.0"))
+	assert.Error(t, validateMaxVersion("2.0.1", "2.0.0"))
+}
+
+func TestIsPermanentFetchError(t *testing.T) {
+
+	assert.True(t, isPermanentFetchError(errors.New("fatal: Authentication failed for 'https://github.com/foo/bar.git/'")))
+	assert.True(t, isPermanentFetchError(errors.New("git@github.com: Permission denied (publickey).")))
+	assert.False(t, isPermanentFetchError(errors.New("fatal: unable to access 'https://github.com/foo/bar.git/': Could not resolve proxy: proxy")))
+}
+
+func TestFetchTagsRetries(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-fetch-retries")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	// the remote runs a script counting the fetch attempts and failing with the given message
+	repo := filepath.Join(f, "repo")
+	assert.NoError(t, os.MkdirAll(repo, 0755))
+	assert.NoError(t, gits.GitInit(repo))
+	assert.NoError(t, gits.GitCmd(repo, "config", "protocol.ext.allow", "always"))
+	script := filepath.Join(f, "remote.sh")
+	count := filepath.Join(f, "count")
+	assert.NoError(t, gits.GitCmd(repo, "remote", "add", "origin", "ext::sh "+script))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(repo))
+	defer os.Chdir(wd)
+
+	testCases := []struct {
+		message  string
+		attempts int
+	}{
+		{"fatal: unable to access the remote: Failed to connect to server", 3},
+		{"fatal: Authentication failed for the remote", 1},
+	}
+	for _, tc := range testCases {
+		os.Remove(count)
+		err = ioutil.WriteFile(script, []byte("echo x >> "+count+"\necho '"+tc.message+"' >&2\nexit 1\n"), 0755)
+		assert.NoError(t, err)
+
+		o := StepNextVersionOptions{
+			FetchRetries:    2,
+			FetchRetryDelay: "1ms",
+		}
+		err = o.fetchTags()
+		assert.Equal(t, errCodeFetchFailed, errorCode(err))
+
+		b, err := ioutil.ReadFile(count)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.attempts, strings.Count(string(b), "x"), "attempts to fetch after %s", tc.message)
+	}
+}
+
+func TestChartAnchors(t *testing.T) {
+
+	o := StepNextVersionOptions{
+		Dir:      "test_data/next_version/helm_anchors",
+		Filename: "Chart.yaml",
 	}
 
 	v, err := o.getVersion()
 
 	assert.NoError(t, err)
 
-	assert.Equal(t, "1.0-SNAPSHOT", v, "error with getVersion for a pom.xml")
+	assert.Equal(t, "1.4.0", v, "error with getVersion for a Chart.yaml with anchors")
+
+	o.ChartField = "appVersion"
+	v, err = o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.4.0", v, "error with getVersion for a Chart.yaml appVersion alias")
 }
 
-func TestChart(t *testing.T) {
+func TestChartQuoted(t *testing.T) {
 
 	o := StepNextVersionOptions{
-		Dir:      "test_data/next_version/helm",
+		Dir:      "test_data/next_version/helm_quoted",
 		Filename: "Chart.yaml",
 	}
 
@@ -52,7 +2869,51 @@ func TestChart(t *testing.T) {
 
 	assert.NoError(t, err)
 
-	assert.Equal(t, "0.0.1-SNAPSHOT", v, "error with getVersion for a pom.xml")
+	assert.Equal(t, "1.2.3-rc.1", v, "error with getVersion for a quoted Chart.yaml version")
+
+	o.ChartField = "appVersion"
+	v, err = o.getVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.3", v, "error with getVersion for a quoted Chart.yaml appVersion")
+
+	b, err := ioutil.ReadFile("test_data/next_version/helm_quoted/Chart.yaml")
+	assert.NoError(t, err)
+
+	f := chartVersionFile{field: chartVersion}
+	output, err := f.Write(b, "1.2.4")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), `version: "1.2.3-rc.1"`, `version: "1.2.4"`, 1), string(output))
+
+	f = chartVersionFile{field: chartAppVersion}
+	output, err = f.Write(b, "1.2.4")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, strings.Replace(string(b), `appVersion: '1.2.3'`, `appVersion: '1.2.4'`, 1), string(output))
+}
+
+func TestChartSyncAppVersion(t *testing.T) {
+	b, err := ioutil.ReadFile("test_data/next_version/helm_quoted/Chart.yaml")
+	assert.NoError(t, err)
+
+	f := chartVersionFile{field: chartVersion, syncAppVersion: true}
+	output, err := f.Write(b, "1.2.4")
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), `version: "1.2.3-rc.1"`, `version: "1.2.4"`, 1)
+	expected = strings.Replace(expected, `appVersion: '1.2.3'`, `appVersion: '1.2.4'`, 1)
+	assert.Equal(t, expected, string(output))
+
+	b, err = ioutil.ReadFile("test_data/next_version/helm/Chart.yaml")
+	assert.NoError(t, err)
+
+	output, err = f.Write(b, "0.0.2")
+	assert.NoError(t, err)
+	expected = strings.Replace(string(b), "version: 0.0.1-SNAPSHOT\n", "version: 0.0.2\nappVersion: 0.0.2\n", 1)
+	assert.Equal(t, expected, string(output), "the appVersion should be added after the version")
 }
 
 func TestSetVersionJavascript(t *testing.T) {
@@ -85,6 +2946,232 @@ func TestSetVersionJavascript(t *testing.T) {
 
 }
 
+func TestWriteOnly(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-write-only")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	testData := path.Join("test_data", "next_version", "javascript")
+	err = util.CopyDir(testData, f, true)
+	assert.NoError(t, err)
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "add", "."))
+	assert.NoError(t, gits.GitCmd(f, "commit", "-m", "first"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{}
+	o.Out = tests.Output()
+	o.Dir = f
+	o.Filename = "package.json"
+	o.WriteOnly = true
+	err = o.Run()
+	assert.Error(t, err, "the version is required")
+
+	o.NewVersion = "1.2.3"
+	err = o.Run()
+	assert.NoError(t, err)
+
+	updatedFile, err := util.LoadBytes(o.Dir, o.Filename)
+	assert.NoError(t, err)
+	// the test data has been copied as the current directory is now the repository
+	testFile, err := util.LoadBytes(f, "expected_package.json")
+	assert.NoError(t, err)
+	assert.Equal(t, string(testFile), string(updatedFile), "replaced version")
+
+	subject, err := o.getCommandOutput(f, "git", "log", "-1", "--format=%s")
+	assert.NoError(t, err)
+	assert.Equal(t, "Release 1.2.3", subject)
+
+	_, err = os.Stat(filepath.Join(f, "VERSION"))
+	assert.True(t, os.IsNotExist(err), "the VERSION file should not be written")
+
+	tags, err := o.getCommandOutput(f, "git", "tag")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func TestRunReadOnlyVersionFiles(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	for dir, name := range map[string]string{"clojure": "project.clj", "meson": "meson.build", "autotools": "configure.ac"} {
+		f, err := ioutil.TempDir("", "test-read-only-"+dir)
+		assert.NoError(t, err)
+		defer os.RemoveAll(f)
+
+		err = util.CopyDir(filepath.Join(wd, "test_data", "next_version", dir), f, true)
+		assert.NoError(t, err)
+		assert.NoError(t, gits.GitInit(f))
+		assert.NoError(t, gits.GitCmd(f, "add", "."))
+		assert.NoError(t, gits.GitCmd(f, "commit", "-m", "first"))
+		assert.NoError(t, os.Chdir(f))
+
+		b, err := ioutil.ReadFile(filepath.Join(f, name))
+		assert.NoError(t, err)
+
+		o := StepNextVersionOptions{}
+		o.Out = tests.Output()
+		o.Dir = f
+		o.Filename = name
+		o.NewVersion = "9.9.9"
+		err = o.Run()
+		assert.NoError(t, err, "a read only %s should not fail the step", name)
+
+		v, err := ioutil.ReadFile(filepath.Join(f, "VERSION"))
+		assert.NoError(t, err)
+		assert.Equal(t, "9.9.9", string(v))
+
+		output, err := ioutil.ReadFile(filepath.Join(f, name))
+		assert.NoError(t, err)
+		assert.Equal(t, string(b), string(output), "%s should be left as is", name)
+
+		count, err := o.getCommandOutput(f, "git", "rev-list", "--count", "HEAD")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", count, "there should be no release commit for %s", name)
+	}
+}
+
+func TestRunNoVersionFile(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-no-version-file")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = util.CopyDir(path.Join("test_data", "next_version", "javascript"), f, true)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(f, "VERSION"), []byte("1.2.3"), 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "add", "."))
+	assert.NoError(t, gits.GitCmd(f, "commit", "-m", "first"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepNextVersionOptions{}
+	o.Out = tests.Output()
+	o.Dir = f
+	o.NewVersion = "1.3.0"
+	o.NoVersionFile = true
+	err = o.Run()
+	assert.NoError(t, err)
+
+	v, err := ioutil.ReadFile(filepath.Join(f, "VERSION"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", string(v), "the VERSION file should not be written")
+
+	status, err := o.getCommandOutput(f, "git", "status", "--porcelain")
+	assert.NoError(t, err)
+	assert.Equal(t, "", status, "the working tree should be left clean")
+
+	count, err := o.getCommandOutput(f, "git", "rev-list", "--count", "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", count, "there should be no release commit")
+
+}
+
+func TestExistingTag(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-existing-tag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = util.CopyDir(path.Join("test_data", "next_version", "javascript"), f, true)
+	assert.NoError(t, err)
+	assert.NoError(t, gits.GitInit(f))
+	assert.NoError(t, gits.GitCmd(f, "add", "."))
+	assert.NoError(t, gits.GitCmd(f, "commit", "-m", "first"))
+	assert.NoError(t, gits.GitCmd(f, "tag", "v1.2.3"))
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	b, err := ioutil.ReadFile(filepath.Join(f, "package.json"))
+	assert.NoError(t, err)
+
+	o := StepNextVersionOptions{}
+	o.Out = tests.Output()
+	o.Dir = f
+	o.Filename = "package.json"
+	o.NewVersion = "1.2.3"
+	o.Tag = true
+	for _, skip := range []bool{false, true} {
+		o.SkipExistingTag = skip
+		err = o.Run()
+		if skip {
+			assert.NoError(t, err)
+		} else {
+			assert.Equal(t, errCodeTagExists, errorCode(err))
+		}
+
+		output, err := ioutil.ReadFile(filepath.Join(f, "package.json"))
+		assert.NoError(t, err)
+		assert.Equal(t, string(b), string(output), "the version file should not be updated for an existing tag")
+
+		count, err := o.getCommandOutput(f, "git", "rev-list", "--count", "HEAD")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", count, "there should be no release commit for an existing tag")
+	}
+}
+
+func TestCommitArgs(t *testing.T) {
+	o := StepNextVersionOptions{}
+	assert.Equal(t, []string{"commit", "-m", "Release 1.2.3"}, o.commitArgs("Release 1.2.3"))
+
+	o.SignCommit = true
+	assert.Equal(t, []string{"commit", "-S", "-m", "Release 1.2.3"}, o.commitArgs("Release 1.2.3"), "the release commit should be signed")
+}
+
+func TestCommitVersionAuthor(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-commit-version")
+	assert.NoError(t, err)
+
+	testData := path.Join("test_data", "next_version", "javascript")
+	err = util.CopyDir(testData, f, true)
+	assert.NoError(t, err)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	o := StepNextVersionOptions{}
+	o.Out = &out
+	o.Dir = f
+	o.Filename = "package.json"
+	o.NewVersion = "1.2.3"
+	o.CommitAuthorName = "release-bot"
+	o.CommitAuthorEmail = "release-bot@example.com"
+	err = o.setVersion()
+	assert.NoError(t, err)
+
+	env := map[string]string{}
+	for _, k := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL"} {
+		env[k] = os.Getenv(k)
+	}
+	err = o.commitVersion("Release 1.2.3")
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "Release 1.2.3", "the output of git should be written to the output of the command")
+
+	author, err := o.getCommandOutput(f, "git", "log", "-1", "--format=%an <%ae> %cn <%ce>")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "release-bot <release-bot@example.com> release-bot <release-bot@example.com>", author)
+
+	for k, v := range env {
+		assert.Equal(t, v, os.Getenv(k), "the git identity of the process should be left alone")
+	}
+}
+
 func TestSetVersionChart(t *testing.T) {
 
 	f, err := ioutil.TempDir("", "test-set-version")
@@ -115,3 +3202,67 @@ func TestSetVersionChart(t *testing.T) {
 	assert.Equal(t, string(testFile), string(updatedFile), "replaced version")
 
 }
+
+func TestSetVersionSnapcraft(t *testing.T) {
+
+	f, err := ioutil.TempDir("", "test-set-version")
+	assert.NoError(t, err)
+
+	testData := path.Join("test_data", "next_version", "snap")
+	_, err = os.Stat(testData)
+	assert.NoError(t, err)
+
+	err = util.CopyDir(testData, f, true)
+	assert.NoError(t, err)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+
+	o := StepNextVersionOptions{}
+	o.Out = tests.Output()
+	o.Dir = f
+	o.Filename = "snapcraft.yaml"
+	o.NewVersion = "1.2.3"
+	b, err := util.LoadBytes(o.Dir, o.Filename)
+	assert.NoError(t, err)
+	err = o.setVersion()
+	assert.NoError(t, err)
+
+	v, err := o.getVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", v, "replaced version")
+
+	updatedFile, err := util.LoadBytes(o.Dir, o.Filename)
+	assert.NoError(t, err)
+	expected := strings.Replace(string(b), "version: '0.4.1'", "version: '1.2.3'", 1)
+	assert.Equal(t, expected, string(updatedFile), "only the top level version should be replaced keeping the comments")
+}
+
+func TestSetVersionBumpversion(t *testing.T) {
+
+	f, err := ioutil.TempDir("", "test-set-version")
+	assert.NoError(t, err)
+
+	testData := path.Join("test_data", "next_version", "bumpversion")
+	_, err = os.Stat(testData)
+	assert.NoError(t, err)
+
+	err = util.CopyDir(testData, f, true)
+	assert.NoError(t, err)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+
+	o := StepNextVersionOptions{}
+	o.Out = tests.Output()
+	o.Dir = f
+	o.Filename = ".bumpversion.cfg"
+	o.NewVersion = "1.2.3"
+	err = o.setVersion()
+	assert.NoError(t, err)
+
+	updatedFile, err := util.LoadBytes(o.Dir, o.Filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(updatedFile), "[bumpversion]\ncurrent_version = 1.2.3\ncommit = True", "replaced version")
+	assert.Contains(t, string(updatedFile), "search = version='{current_version}'", "other sections should be untouched")
+}
@@ -27,6 +27,14 @@ type StepTagOptions struct {
 
 type StepTagFlags struct {
 	Version string
+	// Tag is the name of the tag, defaults to the version prefixed with 'v'
+	Tag string
+	// NoPush only creates the tag locally so it can be pushed later
+	NoPush bool
+	// Quiet discards the output of git rather than writing it to stdout
+	Quiet bool
+	// Message is the message of the annotated tag, defaults to 'release' and the version
+	Message string
 }
 
 var (
@@ -46,6 +54,9 @@ var (
 
 		jx step tag --version 1.0.0
 
+		# create the tag locally and push it later
+		jx step tag --version 1.0.0 --no-push
+
 `)
 )
 
@@ -74,6 +85,7 @@ func NewCmdStepTag(f cmdutil.Factory, out io.Writer, errOut io.Writer) *cobra.Co
 	}
 
 	cmd.Flags().StringVarP(&options.Flags.Version, VERSION, "v", "", "version number for the tag [required]")
+	cmd.Flags().BoolVarP(&options.Flags.NoPush, "no-push", "", false, "only create the tag locally rather than also pushing it to the remote origin")
 
 	return cmd
 }
@@ -83,19 +95,32 @@ func (o *StepTagOptions) Run() error {
 		return errors.New("No version flag")
 	}
 
-	tag := "v" + o.Flags.Version
+	tag := o.Flags.Tag
+	if tag == "" {
+		tag = "v" + o.Flags.Version
+	}
 
-	err := gits.GitCmd("", "commit", "-a", "-m", fmt.Sprintf("release %s", o.Flags.Version), "--allow-empty")
+	err := o.git("commit", "-a", "-m", fmt.Sprintf("release %s", o.Flags.Version), "--allow-empty")
 	if err != nil {
 		return err
 	}
 
-	err = gits.GitCmd("", "tag", "-fa", tag, "-m", fmt.Sprintf("release %s", o.Flags.Version))
+	message := o.Flags.Message
+	if message == "" {
+		message = fmt.Sprintf("release %s", o.Flags.Version)
+	}
+	// keep any markdown headings of a changelog message which git would otherwise strip as comments
+	err = o.git("tag", "-fa", tag, "--cleanup=whitespace", "-m", message)
 	if err != nil {
 		return err
 	}
 
-	err = gits.GitCmd("", "push", "origin", tag)
+	if o.Flags.NoPush {
+		log.Successf("Tag %s created locally, push it with: git push origin %s", tag, tag)
+		return nil
+	}
+
+	err = o.git("push", "origin", tag)
 	if err != nil {
 		return err
 	}
@@ -103,3 +128,12 @@ func (o *StepTagOptions) Run() error {
 	log.Successf("Tag %s created and pushed to remote origin", tag)
 	return nil
 }
+
+// git runs the git command in the current directory, discarding its output if quiet
+func (o *StepTagOptions) git(args ...string) error {
+	if o.Flags.Quiet {
+		_, err := o.getCommandOutput("", "git", args...)
+		return err
+	}
+	return gits.GitCmd("", args...)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepTagNoPush(t *testing.T) {
+	f, err := ioutil.TempDir("", "test-step-tag-no-push")
+	assert.NoError(t, err)
+	defer os.RemoveAll(f)
+
+	err = gits.GitInit(f)
+	assert.NoError(t, err)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(f)
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+
+	o := StepTagOptions{
+		Flags: StepTagFlags{
+			Version: "1.0.0",
+			NoPush:  true,
+		},
+	}
+	// there is no remote origin so pushing would fail
+	err = o.Run()
+	assert.NoError(t, err)
+
+	out, err := o.getCommandOutput(f, "git", "tag", "--list")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.0.0", out)
+
+	o.Flags.Version = "1.0.1"
+	o.Flags.NoPush = false
+	err = o.Run()
+	assert.Error(t, err)
+}
@@ -0,0 +1,6 @@
+(defproject my-app "1.2.3-SNAPSHOT"
+  :description "FIXME: write description"
+  :url "http://example.com/FIXME"
+  :dependencies [[org.clojure/clojure "1.9.0"]]
+  :main ^:skip-aot my-app.core
+  :profiles {:uberjar {:aot :all}})
@@ -0,0 +1,10 @@
+from conan import ConanFile
+
+
+class MyLibConan(ConanFile):
+    name = "my-lib"
+    version = "1.3.0"
+    requires = "fmt/9.1.0"
+
+    def package_info(self):
+        self.cpp_info.libs = ["my-lib"]
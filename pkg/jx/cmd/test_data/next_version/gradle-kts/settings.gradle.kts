@@ -0,0 +1,8 @@
+rootProject.name = "my-app"
+
+gradle.rootProject {
+    group = "io.jenkins-x"
+    version = "2.0.1"
+}
+
+include("core")
@@ -0,0 +1,19 @@
+{ lib, buildGoModule, fetchFromGitHub }:
+
+buildGoModule rec {
+  pname = "my-tool";
+  # the released version of the tool
+  version = "1.4.2";
+
+  src = fetchFromGitHub {
+    owner = "jenkins-x";
+    repo = pname;
+    rev = "v${version}";
+    sha256 = "0000000000000000000000000000000000000000000000000000";
+  };
+
+  meta = with lib; {
+    description = "a tool released with jx";
+    license = licenses.asl20;
+  };
+}
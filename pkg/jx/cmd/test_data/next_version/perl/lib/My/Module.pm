@@ -0,0 +1,13 @@
+package My::Module;
+
+use strict;
+use warnings;
+
+# the released version of the module
+our $VERSION = '1.23';
+
+sub version_string {
+    return "My::Module $VERSION";
+}
+
+1;
@@ -0,0 +1,8 @@
+Name:		my-app
+Version:	2.0.1
+Release:	3%{?dist}
+Summary:	My app
+License:	ASL 2.0
+
+%description
+My app, the Version: of which is set above.
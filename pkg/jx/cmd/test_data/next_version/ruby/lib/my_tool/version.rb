@@ -0,0 +1,7 @@
+# frozen_string_literal: true
+
+module MyTool
+  # the released version of the tool
+  VERSION = "0.9.2".freeze
+  API_VERSION = "2"
+end
@@ -0,0 +1,15 @@
+// swift-tools-version:4.0
+import PackageDescription
+
+let package = Package(
+    name: "MyLib",
+    products: [
+        .library(name: "MyLib", targets: ["MyLib"]),
+    ],
+    dependencies: [
+        .package(url: "https://github.com/apple/swift-nio.git", from: "1.9.0"),
+    ],
+    targets: [
+        .target(name: "MyLib", dependencies: ["NIO"]),
+    ]
+)
@@ -0,0 +1,11 @@
+terraform {
+  required_version = ">= 0.11.0"
+}
+
+locals {
+  module_version = "0.7.1"
+}
+
+variable "region" {
+  default = "us-east-1"
+}
@@ -0,0 +1,20 @@
+<?php
+/**
+ * Plugin Name:       My Plugin
+ * Plugin URI:        https://example.com/my-plugin
+ * Description:       A plugin released with jx.
+ * Version:           1.4.0
+ * Requires at least: 5.2
+ * Requires PHP:      7.2
+ * Author:            Jenkins X
+ * License:           GPL v2 or later
+ */
+
+if ( ! defined( 'ABSPATH' ) ) {
+	exit;
+}
+
+/*
+ * Version: 0.0.1 of the settings schema
+ */
+define( 'MY_PLUGIN_SCHEMA', '0.0.1' );
@@ -2,23 +2,50 @@ package log
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/fatih/color"
 )
 
+var quiet bool
+
+// SetQuiet suppresses the info and success logging and writes warnings and errors to stderr so that a command can
+// print just its result to stdout
+func SetQuiet(q bool) {
+	quiet = q
+}
+
+// printStderr writes the coloured message to stderr followed by a newline like the color print functions
+func printStderr(attr color.Attribute, msg string) {
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	color.New(attr).Fprint(os.Stderr, msg)
+}
+
 func Infof(msg string, args ...interface{}) {
 	Info(fmt.Sprintf(msg, args...))
 }
 
 func Info(msg string) {
+	if quiet {
+		return
+	}
 	fmt.Print(msg)
 }
 
 func Infoln(msg string) {
+	if quiet {
+		return
+	}
 	fmt.Println(msg)
 }
 
 func Blank() {
+	if quiet {
+		return
+	}
 	fmt.Println()
 }
 
@@ -27,6 +54,10 @@ func Warnf(msg string, args ...interface{}) {
 }
 
 func Warn(msg string) {
+	if quiet {
+		printStderr(color.FgYellow, msg)
+		return
+	}
 	color.Yellow(msg)
 }
 
@@ -35,6 +66,10 @@ func Errorf(msg string, args ...interface{}) {
 }
 
 func Error(msg string) {
+	if quiet {
+		printStderr(color.FgRed, msg)
+		return
+	}
 	color.Red(msg)
 }
 
@@ -47,6 +82,9 @@ func Fatal(msg string) {
 }
 
 func Success(msg string) {
+	if quiet {
+		return
+	}
 	color.Green(msg)
 }
 